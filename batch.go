@@ -0,0 +1,186 @@
+// Batch simulation. Runs many headless outbreaks from the same start, and summarises how they went.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/3541/zombies/entity"
+)
+
+// Run n outbreaks across jobs goroutines, set up from a map and optional scenario, with a zombie placed at start
+// if it is given. Run i is seeded with seed+i. Runs which can't be set up are reported, and left out of the outcomes.
+func runBatch(mapPath string, scenarioPath string, start string, t entity.Tunables, n int, jobs int, seed int64) ([]entity.Outcome, error) {
+	// Make sure everything loads, so the workers don't have to report it
+	g := entity.NewMapGraph(entity.R(0, 0, 1000, 1000), 25)
+	err := setupWorld(g, mapPath, scenarioPath, seed)
+	if err != nil {
+		return nil, err
+	}
+	if start != "" && g.GetVertexByName(start) == nil {
+		return nil, fmt.Errorf("no vertex named %q", start)
+	}
+
+	outcomes := make([]entity.Outcome, n)
+	failures := make([]error, n)
+	runs := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range runs {
+				// Each run needs a graph of its own
+				g := entity.NewMapGraph(entity.R(0, 0, 1000, 1000), 25)
+				// Placement can still fail with some seeds
+				if err := setupWorld(g, mapPath, scenarioPath, seed+int64(i)); err != nil {
+					failures[i] = err
+					continue
+				}
+				g.Tunables = t
+				if start != "" {
+					g.AddNewZombie(g.GetVertexByName(start))
+				}
+				g.StartEntities()
+				outcomes[i] = g.RunOutbreak()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		runs <- i
+	}
+	close(runs)
+	wg.Wait()
+
+	var ret []entity.Outcome
+	for i, o := range outcomes {
+		if failures[i] != nil {
+			fmt.Fprintf(os.Stderr, "Run with seed %d failed: %v\n", seed+int64(i), failures[i])
+			continue
+		}
+		ret = append(ret, o)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("all %d runs failed", n)
+	}
+	return ret, nil
+}
+
+func batchMain(args []string) {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	n := flags.Int("n", 100, "number of outbreaks to run")
+	mapPath := flags.String("map", "map.json", "map file to load")
+	start := flags.String("start", "", "name of the vertex to place the first zombie on")
+	jobs := flags.Int("j", runtime.NumCPU(), "number of outbreaks to run at once")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed for the first outbreak; each after it uses the next")
+	scenarioPath := flags.String("scenario", "", "scenario to set up, on its own map if it names one")
+	flags.Parse(args)
+
+	if (*start == "" && *scenarioPath == "") || *n < 1 || *jobs < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	began := time.Now()
+	outcomes, err := runBatch(*mapPath, *scenarioPath, *start, entity.DefaultTunables, *n, *jobs, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	from := *start
+	if *scenarioPath != "" {
+		from = *scenarioPath
+		if *start != "" {
+			from += " and " + *start
+		}
+	}
+	fmt.Printf("Ran %d outbreaks from %s (seeds %d to %d) in %s.\n", *n, from, *seed, *seed+int64(*n)-1, time.Since(began).Round(time.Millisecond))
+	if failed := *n - len(outcomes); failed > 0 {
+		fmt.Printf("%d failed to set up, and are left out.\n", failed)
+	}
+	fmt.Println()
+
+	printSummary(outcomes)
+}
+
+func printSummary(outcomes []entity.Outcome) {
+	var survivors, peaks, lengths, extinctions []float64
+	deaths := make(map[string]int)
+	for _, o := range outcomes {
+		survivors = append(survivors, float64(o.Survivors))
+		peaks = append(peaks, float64(o.PeakZombies))
+		length := (time.Duration(o.Ticks) * entity.TickDuration).Minutes()
+		lengths = append(lengths, length)
+		if o.Survivors == 0 {
+			extinctions = append(extinctions, length)
+		}
+
+		for cause, c := range o.Deaths {
+			deaths[cause.String()] += c
+		}
+		if o.Infected > 0 {
+			deaths["INFECTED"] += o.Infected
+		}
+	}
+
+	fmt.Printf("Someone survived in %d of %d outbreaks (%.1f%%).\n\n", len(outcomes)-len(extinctions), len(outcomes), 100*float64(len(outcomes)-len(extinctions))/float64(len(outcomes)))
+
+	fmt.Printf("%-32s %8s %8s %8s %8s %8s %8s\n", "", "MIN", "P10", "MEDIAN", "MEAN", "P90", "MAX")
+	printDistribution("Survivors", survivors)
+	printDistribution("Peak zombies", peaks)
+	printDistribution("Outbreak length (minutes)", lengths)
+	printDistribution("Time to extinction (minutes)", extinctions)
+
+	causes := make([]string, 0, len(deaths))
+	total := 0
+	for cause, c := range deaths {
+		causes = append(causes, cause)
+		total += c
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if deaths[causes[i]] != deaths[causes[j]] {
+			return deaths[causes[i]] > deaths[causes[j]]
+		}
+		return causes[i] < causes[j]
+	})
+
+	if total == 0 {
+		fmt.Println("\nNobody died.")
+		return
+	}
+	fmt.Println("\nHow people died:")
+	for _, cause := range causes {
+		fmt.Printf("  %-20s %8d (%.1f%%)\n", cause, deaths[cause], 100*float64(deaths[cause])/float64(total))
+	}
+	fmt.Printf("Most common cause of death: %s\n", causes[0])
+}
+
+func printDistribution(name string, values []float64) {
+	if len(values) == 0 {
+		fmt.Printf("%-32s %8s\n", name, "-")
+		return
+	}
+
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	fmt.Printf("%-32s %8.1f %8.1f %8.1f %8.1f %8.1f %8.1f\n", name, values[0], percentile(values, 10), percentile(values, 50), sum/float64(len(values)), percentile(values, 90), values[len(values)-1])
+}
+
+// The pth percentile of sorted values, by nearest rank
+func percentile(values []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
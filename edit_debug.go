@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/3541/zombies/entity"
 	"github.com/3541/zombies/vis"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
@@ -37,14 +38,15 @@ type editorState struct {
 	fontFace   font.Face
 
 	window *pixelgl.Window
-	g      *vis.MapGraph
+	w      *vis.VWindow
+	g      *entity.MapGraph
 
 	currentState state
 	input        *inputState
 
-	tempVertex *vis.PositionedNode
+	tempVertex *entity.PositionedNode
 	tempEdge   *simple.Edge
-	selected   *vis.PositionedNode
+	selected   *entity.PositionedNode
 }
 
 type inputState struct {
@@ -63,7 +65,7 @@ func editGraph(camera pixel.Matrix) {
 
 	// Reset editor if escape is pressed
 	if editor.window.JustPressed(pixelgl.KeyEscape) {
-		editInit(editor.window, editor.g, editor.fontFace)
+		editInit(editor.window, editor.w, editor.fontFace)
 		return
 	}
 
@@ -88,10 +90,12 @@ func editGraph(camera pixel.Matrix) {
 				editor.statusText.WriteString(editor.input.prompt)
 			} else {
 				editor.selected = v
-				v.Selected = true
+				editor.w.Selected = v
 				editor.currentState = Selected
 				editor.g.Changed = true
 			}
+		} else if editor.window.JustPressed(pixelgl.KeyF7) {
+			populateEditor()
 		}
 	case Input:
 		if editor.window.JustPressed(pixelgl.KeyEnter) {
@@ -127,14 +131,14 @@ func editGraph(camera pixel.Matrix) {
 			cv := clickedVertex(pos)
 			if cv == nil {
 				editor.currentState = Main
-				editor.selected.Selected = false
+				editor.w.Selected = nil
 				editor.selected = nil
 				editor.g.Changed = true
 			} else {
 				editor.currentState = Input
 				editor.input = &inputState{"Edge weight: ", CreateEdge, new(bytes.Buffer)}
-				editor.tempEdge = &simple.Edge{simple.Node(editor.selected.ID()), simple.Node(cv.ID()), 1}
-				editor.selected.Selected = false
+				editor.tempEdge = &simple.Edge{F: simple.Node(editor.selected.ID()), T: simple.Node(cv.ID()), W: 1}
+				editor.w.Selected = nil
 				editor.selected = nil
 				editor.statusText.Clear()
 				editor.statusText.WriteString(editor.input.prompt)
@@ -145,6 +149,7 @@ func editGraph(camera pixel.Matrix) {
 			}
 			editor.g.RemoveNode(editor.selected)
 			editor.currentState = Main
+			editor.w.Selected = nil
 			editor.selected = nil
 			editor.g.Changed = true
 		}
@@ -166,7 +171,7 @@ func editGraph(camera pixel.Matrix) {
 	}
 }
 
-func clickedVertex(pos pixel.Vec) *vis.PositionedNode {
+func clickedVertex(pos pixel.Vec) *entity.PositionedNode {
 	for _, v := range editor.g.Nodes() {
 		if math.Sqrt(math.Pow(pos.X-v.Pos.X, 2)+math.Pow(pos.Y-v.Pos.Y, 2)) < editor.g.VertexSize {
 			return v
@@ -175,11 +180,26 @@ func clickedVertex(pos pixel.Vec) *vis.PositionedNode {
 	return nil
 }
 
+// Replace everyone on the map with people placed by the population rules, using the -seed RNG
+func populateEditor() {
+	editor.statusText.Clear()
+	rules, err := entity.LoadPopulationRules(POPULATION_PATH)
+	if err == nil {
+		err = rules.Populate(editor.g)
+	}
+	if err != nil {
+		fmt.Fprintf(editor.statusText, "Couldn't populate the map: %s", err)
+		return
+	}
+	people, _ := editor.g.Population()
+	fmt.Fprintf(editor.statusText, "Placed %d people following %s. They will be saved with the map.", people, POPULATION_PATH)
+}
+
 //func editGraph(camera pixel.Matrix) {}
 
-func editInit(window *pixelgl.Window, g *vis.MapGraph, fontFace font.Face) {
+func editInit(window *pixelgl.Window, w *vis.VWindow, fontFace font.Face) {
 	atlas := text.NewAtlas(fontFace, text.ASCII)
-	editor = editorState{atlas, text.New(pixel.V(10, window.Bounds().H()-50), atlas), fontFace, window, g, Main, nil, nil, nil, nil}
+	editor = editorState{atlas, text.New(pixel.V(10, window.Bounds().H()-50), atlas), fontFace, window, w, w.Graph, Main, nil, nil, nil, nil}
 	editor.statusText.Color = colornames.Black
 
 	fmt.Fprintln(editor.statusText, "NOTE: This is a DEBUG build with the map editor enabled.")
@@ -187,10 +207,11 @@ func editInit(window *pixelgl.Window, g *vis.MapGraph, fontFace font.Face) {
 	fmt.Fprintln(editor.statusText, "Or press the 'a' key to add a vertex at a specific position.")
 	fmt.Fprintln(editor.statusText, "Click on a vertex to select it, then press delete to delete it, or click on another vertex to connect them.")
 	fmt.Fprintln(editor.statusText, "Clicking two vertices already connected by an edge and entering a weight of 0 deletes the edge.")
+	fmt.Fprintln(editor.statusText, "Press F7 to replace everyone on the map with people placed by the rules in "+POPULATION_PATH+".")
 	fmt.Fprintln(editor.statusText, "Press the escape key to reset the editor. No graph data will be lost, but any current editing actions will be removed,\nand this message will display again.")
 }
 
-func editEnd(window *pixelgl.Window, g *vis.MapGraph) {
+func editEnd(window *pixelgl.Window, g *entity.MapGraph) {
 	window.SetMonitor(nil)
 	window.SetBounds(pixel.R(0, 0, 1, 1))
 	var in string
@@ -1,3 +1,5 @@
+// +build !debug
+
 // Stub implementations of map editor functions. For release builds.
 
 package main
@@ -6,6 +8,7 @@ import (
 	"math"
 
 	"github.com/3541/zombies/entity"
+	"github.com/3541/zombies/vis"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
 	"golang.org/x/image/font"
@@ -24,16 +27,17 @@ func editGraph(camera pixel.Matrix) {
 	} else if window.JustPressed(pixelgl.MouseButtonRight) {
 		clicked := clickedVertex(camera.Unproject(window.MousePosition()))
 		if clicked != nil {
-			for _, p := range clicked.People {
+			// Infecting someone removes them from the vertex, so work from a copy
+			for _, p := range append([]*entity.Person(nil), clicked.People...) {
 				graph.InfectPerson(p)
 			}
 		}
 	}
 }
 
-func editInit(w *pixelgl.Window, g *entity.MapGraph, font font.Face) {
+func editInit(w *pixelgl.Window, vw *vis.VWindow, font font.Face) {
 	window = w
-	graph = g
+	graph = vw.Graph
 }
 
 func editEnd(window *pixelgl.Window, g *entity.MapGraph) {}
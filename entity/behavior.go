@@ -1,169 +1,257 @@
 package entity
 
-import (
-	"fmt"
-	"math/rand"
-	"time"
-)
-
-func pause(t int, unit time.Duration) {
-	<-time.NewTimer(time.Duration(t) * unit).C
-}
+// Advance a person by one tick
+func (p *Person) Tick(g *MapGraph) {
+	if p.dead {
+		return
+	}
 
-func (p *Person) Live(g *MapGraph) {
-	p.Kill = make(chan string, 100)
-	p.Damage = make(chan DamageMessage, 100)
-	pause(rand.Intn(2000), time.Millisecond)
-	tick := time.NewTicker(500 * time.Millisecond)
-	defer g.RemovePerson(p)
-	for _ = range tick.C {
-		if p.checkKilled(g) {
+	// Still waiting to start, or on the way to another vertex
+	if p.wait > 0 {
+		// Just set off with their squad
+		if p.departed == g.Clock.Ticks {
 			return
 		}
-
-		//		g.Log <- fmt.Sprintf("%s is at %s with %v", p.Profession, g.Node(p.Location).Name, p.Items)
-		g.Mutex.RLock()
-		if p.Hunger >= 400 {
-			p.Kill <- "STARVED to DEATH"
-		} else if p.Thirst >= 300 {
-			p.Kill <- "DIED of THIRST"
-		}
-		g.Mutex.RUnlock()
-
-	loop:
-		for {
-			select {
-			case m := <-p.Damage:
-				g.Mutex.Lock()
-				p.Health -= int(m.Value)
-				if p.Health <= 0 {
-					p.Kill <- fmt.Sprintf("killed by %s with %s", m.Attacker, m.Item.StringLong())
-					g.Mutex.Unlock()
-					p.checkKilled(g)
-					return
-				} else {
-					g.Log <- fmt.Sprintf("%s at %s takes %d damage from %s wielding %s. Now at %d HP", p.Profession, g.Node(p.Location).Name, m.Value, m.Attacker, m.Item.StringLong(), p.Health)
-				}
-				g.Mutex.Unlock()
-			default:
-				break loop
-			}
+		p.wait--
+		if p.wait == 0 && p.dest != nil {
+			p.arrive(g)
 		}
+		return
+	}
+
+	if p.Hunger >= g.Tunables.Starvation {
+		p.kill(g, Starvation, nil)
+		return
+	} else if p.Thirst >= g.Tunables.Dehydration {
+		p.kill(g, Thirst, nil)
+		return
+	}
 
-		if p.checkKilled(g) {
+	if p.takeDamage(g) {
+		return
+	}
+
+	currentNode := g.Node(p.Location)
+
+	p.Hunger += p.Profession.def().HungerRate
+	p.Thirst += p.Profession.def().ThirstRate
+
+	// Run, unless there's a fight here worth having. Squads stand or run together.
+	if p.leads() && (len(currentNode.Zombies) == 0 || !p.squadArmed()) {
+		if t := p.fleeStep(g); t != nil {
+			p.setOff(g, currentNode, t, true)
 			return
 		}
+	}
 
-		currentNode := g.Node(p.Location)
+	if len(currentNode.Zombies) > 0 {
+		weapon := p.BestWeapon()
+		z := p.target(g, currentNode)
+		z.damage = append(z.damage, DamageMessage{weapon.Damage(), p.actor(), weapon})
+		if weapon.Consumable() {
+			p.consume(g, weapon)
+		}
+		return
+	}
 
-		g.Mutex.Lock()
+	if p.hungry() && (p.Holding(EnergyBar) || p.askSquad(g, EnergyBar)) {
+		p.consume(g, EnergyBar)
+		p.Hunger -= 100
+		return
+	}
 
-		p.Hunger++
-		p.Thirst++
+	if p.thirsty() {
+		if currentNode.ItemPresent(Water) {
+			p.Thirst -= 100
+			return
+		} else if p.Holding(WaterBottle) || p.askSquad(g, WaterBottle) {
+			p.consume(g, WaterBottle)
+			p.Thirst -= 100
+			return
+		}
+	}
 
-		if len(currentNode.Zombies) > 0 {
-			weapon := p.BestWeapon()
-			minHealth := currentNode.Zombies[0].Health
-			weakest := 0
-			for i := range currentNode.Zombies {
-				if currentNode.Zombies[i].Health < minHealth {
-					minHealth = currentNode.Zombies[i].Health
-					weakest = i
-				}
-			}
-			g.Mutex.Unlock()
-			currentNode.Zombies[weakest].Damage <- DamageMessage{weapon.Damage(), p.Profession.String(), weapon}
-			if weapon.Consumable() {
-				p.ConsumeItem(weapon)
+	// Go and get whatever is needed most. Squad leaders go for whatever the squad needs most.
+	wants := p.need(g)
+	if wants != nil {
+		for i, item := range currentNode.Items {
+			if wants(item) && !item.Fixed() {
+				p.pickUp(g, currentNode, i)
+				return
 			}
-			continue
 		}
+	}
+	if p.squad != nil {
+		if p.squad.Leader != p {
+			wants = nil
+		} else if p.squad.gathering(g, currentNode) {
+			return
+		} else {
+			wants = p.squad.need(g)
+		}
+	}
+	if wants == nil {
+		p.goal = nil
+	} else if t := p.seek(g, wants); t != nil {
+		p.setOff(g, currentNode, t, false)
+		return
+	}
 
-		if p.Hunger >= 100 && p.Holding(EnergyBar) {
-			p.ConsumeItem(EnergyBar)
-			p.Hunger -= 100
-			//			g.Log <- fmt.Sprintf("%s ate an ENERGY BAR at %s", p.Profession, currentNode.Name)
-			g.Mutex.Unlock()
-			continue
+	if len(currentNode.Items) > 0 && (p.slots() < g.Tunables.InventoryCap || p.holdingStackable()) {
+		i := g.Rand.Intn(len(currentNode.Items))
+		item := currentNode.Items[i]
+		if !item.Fixed() && p.canCarry(item, g.Tunables.InventoryCap) {
+			p.pickUp(g, currentNode, i)
+			return
 		}
+	}
 
-		if p.Thirst >= 100 {
-			if currentNode.ItemPresent(Water) {
-				p.Thirst -= 100
-				//				g.Log <- fmt.Sprintf("%s took a drink at %s", p.Profession, currentNode.Name)
-				g.Mutex.Unlock()
-				continue
-			} else if p.Holding(WaterBottle) {
-				p.ConsumeItem(WaterBottle)
-				p.Thirst -= 100
-				//				g.Log <- fmt.Sprintf("%s drank a WATER BOTTLE at %s", p.Profession, currentNode.Name)
-				g.Mutex.Unlock()
-				continue
-			}
+	if p.leads() && g.Rand.Intn(g.Tunables.MoveChance) == 0 {
+		n := g.Neighbors(currentNode)
+		if len(n) == 0 {
+			return
+		}
+		t := n[g.Rand.Intn(len(n))]
+		// Nobody walks towards zombies
+		if len(t.Zombies) > 0 {
+			return
 		}
+		p.setOff(g, currentNode, t, false)
+	}
+}
+
+// Set off for a neighbouring vertex
+func (p *Person) travel(g *MapGraph, from *PositionedNode, t *PositionedNode) {
+	// Humans only pay attention to edge weights because zombies are (presumably) too stupid to fortify
+	p.dest = t
+	p.wait = secondsToTicks(g.Edge(from, t).Weight())
+}
+
+/*
+** The goal planner. Thirst comes first, since it kills soonest, then hunger, then finding a weapon.
+** Returns which items would meet the most pressing need, or nil if there's nothing worth going out of the way for.
+ */
+func (p *Person) need(g *MapGraph) func(Item) bool {
+	switch {
+	case p.thirsty() && !p.Holding(WaterBottle):
+		return p.wantsWater(g)
+	case p.hungry() && !p.Holding(EnergyBar):
+		return p.wantsFood(g)
+	case !p.armed():
+		return p.wantsWeapon(g)
+	}
+	return nil
+}
+
+func (p *Person) carry(g *MapGraph, item Item) bool {
+	return item.Fixed() || p.canCarry(item, g.Tunables.InventoryCap)
+}
+
+func (p *Person) wantsWater(g *MapGraph) func(Item) bool {
+	return func(item Item) bool {
+		return item == Water || (item == WaterBottle && p.carry(g, item))
+	}
+}
 
-		if len(p.Items) < 3 && len(currentNode.Items) > 0 {
-			i := rand.Intn(len(currentNode.Items))
-			if currentNode.Items[i] != Water {
-				p.Items = append(p.Items, currentNode.Items[i])
-				//				g.Log <- fmt.Sprintf("%s picked up %s at %s", p.Profession, currentNode.Items[i].StringLong(), currentNode.Name)
-				currentNode.Items = append(currentNode.Items[:i], currentNode.Items[i+1:]...)
-				currentNode.RenderName(g.atlas)
-				g.Mutex.Unlock()
-				continue
+func (p *Person) wantsFood(g *MapGraph) func(Item) bool {
+	return func(item Item) bool {
+		return item == EnergyBar && p.carry(g, item)
+	}
+}
+
+func (p *Person) wantsWeapon(g *MapGraph) func(Item) bool {
+	return func(item Item) bool {
+		return item.Damage() > Nothing.Damage() && !item.Fixed() && p.carry(g, item)
+	}
+}
+
+/*
+** The first step towards the nearest vertex with something wanted, keeping clear of zombies, or nil if there isn't one.
+** That vertex stays the goal for as long as it still has something wanted and can be got to, so later steps
+** look the way up in the path table, or find it with A* if zombies are in the way, rather than searching again.
+ */
+func (p *Person) seek(g *MapGraph, wants func(Item) bool) *PositionedNode {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	has := func(v *PositionedNode) bool {
+		for _, item := range v.Items {
+			if wants(item) {
+				return true
 			}
 		}
-		g.Mutex.Unlock()
-
-		if rand.Intn(100) == 1 {
-			g.Mutex.RLock()
-			n := g.From(g.Node(p.Location))
-			t := n[rand.Intn(len(n))].(*PositionedNode)
-			g.Mutex.RUnlock()
-			if len(t.Zombies) > 0 {
-				return
-			}
-			// Humans only pay attention to edge weights because zombies are (presumably) too stupid to fortify
-			pause(int(g.Edge(g.Node(p.Location), t).Weight()), time.Second)
-
-		loop1:
-			for {
-				select {
-				case m := <-p.Damage:
-					g.Mutex.Lock()
-					p.Health -= int(m.Value)
-					if p.Health <= 0 {
-						p.Kill <- fmt.Sprintf("killed by %s with %s", m.Attacker, m.Item.StringLong())
-						g.Mutex.Unlock()
-						p.checkKilled(g)
-						return
-					} else {
-						g.Log <- fmt.Sprintf("%s at %s takes %d damage from %s wielding %s. Now at %d HP", p.Profession, g.Node(p.Location).Name, m.Value, m.Attacker, m.Item.StringLong(), p.Health)
-					}
-					g.Mutex.Unlock()
-				default:
-					break loop1
-				}
-			}
-			// Did it die before getting to the next vertex?
-			if p.checkKilled(g) {
-				return
-			}
-			//			g.Log <- fmt.Sprintf("%s moves from %s to %s", p.Profession, g.Node(p.Location).Name, t.Name)
-			p.moveTo(g, t)
+		return false
+	}
+	from := g.Node(p.Location)
+
+	// The editor may have removed it
+	if p.goal != nil && g.Has(p.goal) && g.Node(p.goal.Id) == p.goal && has(p.goal) {
+		if step, ok := g.clearStep(from, p.goal, hasZombies); ok {
+			return step
+		}
+		if path := g.shortestPathAvoiding(from, p.goal, hasZombies); path != nil {
+			return path.FirstStep()
 		}
 	}
+
+	path := g.nearestPathAvoiding(from, has, hasZombies)
+	p.goal = nil
+	if path != nil {
+		p.goal = path.Vertices[len(path.Vertices)-1]
+	}
+	return path.FirstStep()
 }
 
-func (p *Person) checkKilled(g *MapGraph) bool {
-	select {
-	case reason := <-p.Kill:
-		g.RemovePerson(p)
-		g.Log <- fmt.Sprintf("%s %s at %s", p.Profession, reason, g.Node(p.Location).Name)
-		return true
-	default:
-		return false
+func (p *Person) pickUp(g *MapGraph, n *PositionedNode, i int) {
+	item := n.Items[i]
+	p.Items = append(p.Items, item)
+	n.Items = append(n.Items[:i], n.Items[i+1:]...)
+	g.Changed = true
+	g.emit(Event{Kind: Pickup, Actor: p.actor(), Vertex: n.ID(), To: -1, Item: item})
+}
+
+// Apply the damage taken since the last tick. Returns true if it was fatal.
+func (p *Person) takeDamage(g *MapGraph) bool {
+	for _, m := range p.damage {
+		p.Health -= int(m.Value)
+		g.emit(Event{Kind: Attack, Actor: m.Attacker, Target: p.actor(), Vertex: p.Location, To: -1, Item: m.Item, Value: int(m.Value), Health: p.Health})
+		if p.Health <= 0 {
+			p.damage = nil
+			p.kill(g, Killed, &m)
+			return true
+		}
+	}
+	p.damage = p.damage[:0]
+	return false
+}
+
+// Remove a dead person from the map. by is the fatal blow, if there was one.
+func (p *Person) kill(g *MapGraph, cause Cause, by *DamageMessage) {
+	p.dead = true
+	g.RemovePerson(p)
+	e := Event{Kind: Death, Actor: p.actor(), Vertex: p.Location, To: -1, Cause: cause}
+	if by != nil {
+		e.Target = by.Attacker
+		e.Item = by.Item
 	}
+	g.emit(e)
+}
+
+func (p *Person) consume(g *MapGraph, t Item) {
+	p.ConsumeItem(t)
+	g.emit(Event{Kind: Consume, Actor: p.actor(), Vertex: p.Location, To: -1, Item: t})
+}
+
+// Finish travelling to the destination vertex
+func (p *Person) arrive(g *MapGraph) {
+	t := p.dest
+	p.dest = nil
+	// Did it die before getting to the next vertex?
+	if p.takeDamage(g) {
+		return
+	}
+	from := p.Location
+	p.moveTo(g, t)
+	g.emit(Event{Kind: Move, Actor: p.actor(), Vertex: from, To: t.ID()})
 }
 
 func (p *Person) moveTo(g *MapGraph, t *PositionedNode) {
@@ -192,97 +280,111 @@ func (p *Person) moveTo(g *MapGraph, t *PositionedNode) {
 	g.Mutex.Unlock()
 }
 
-func (z *Zombie) Unlive(g *MapGraph) {
-	pause(rand.Intn(2000), time.Millisecond)
-	tick := time.NewTicker(500 * time.Millisecond)
-	for _ = range tick.C {
-		g.Mutex.RLock()
-		if z.Hunger >= 150 {
-			z.Kill <- "STARVED to DEATH"
+// Advance a zombie by one tick
+func (z *Zombie) Tick(g *MapGraph) {
+	if z.dead {
+		return
+	}
+
+	if z.wait > 0 {
+		z.wait--
+		if z.wait == 0 && z.dest != nil {
+			z.arrive(g)
 		}
-		g.Mutex.RUnlock()
-
-		// Check for and take any incoming damage, displaying messages required
-	loop:
-		for {
-			select {
-			case m := <-z.Damage:
-				g.Mutex.Lock()
-				z.Health -= int(m.Value)
-				if z.Health <= 0 {
-					z.Kill <- fmt.Sprintf("killed by %s with %s", m.Attacker, m.Item.StringLong())
-					g.Mutex.Unlock()
-					z.checkKilled(g)
-					return
-				} else {
-					g.Log <- fmt.Sprintf("ZOMBIE at %s takes %d damage from %s wielding %s. Now at %d HP", g.Node(z.Location).Name, m.Value, m.Attacker, m.Item.StringLong(), z.Health)
-				}
-				g.Mutex.Unlock()
-			default:
-				break loop
+		return
+	}
+
+	if z.Hunger >= g.Tunables.ZombieStarvation {
+		z.kill(g, Starvation, nil)
+		return
+	}
+
+	// Take any incoming damage
+	if z.takeDamage(g) {
+		return
+	}
+
+	currentNode := g.Node(z.Location)
+	z.Hunger++
+
+	if len(currentNode.People) > 0 {
+		t := currentNode.People[g.Rand.Intn(len(currentNode.People))]
+		if int(z.Holding.Damage()) >= t.Health {
+			g.infect(z, t)
+			z.Health = 100
+			z.Hunger = 0
+		} else {
+			t.damage = append(t.damage, DamageMessage{z.Holding.Damage(), z.actor(), z.Holding})
+			if z.Holding.Consumable() {
+				g.emit(Event{Kind: Consume, Actor: z.actor(), Vertex: z.Location, To: -1, Item: z.Holding})
+				z.Holding = Nothing
 			}
 		}
+		return
+	}
 
-		if z.checkKilled(g) {
+	if z.Holding == Nothing && len(currentNode.Items) > 0 {
+		i := g.Rand.Intn(len(currentNode.Items))
+		if currentNode.Items[i].Wieldable() {
+			z.Holding = currentNode.Items[i]
+			currentNode.Items = append(currentNode.Items[:i], currentNode.Items[i+1:]...)
+			g.Changed = true
+			g.emit(Event{Kind: Pickup, Actor: z.actor(), Vertex: currentNode.ID(), To: -1, Item: z.Holding})
 			return
 		}
+	}
 
-		currentNode := g.Node(z.Location)
-		g.Mutex.Lock()
-		z.Hunger++
-
-		if len(currentNode.People) > 0 {
-			t := currentNode.People[rand.Intn(len(currentNode.People))]
-			if int(z.Holding.Damage()) >= t.Health {
-				g.InfectPerson(t)
-				z.Health = 100
-				z.Hunger = 0
-				g.Log <- fmt.Sprintf("ZOMBIE INFECTED %s at %s", t.Profession, currentNode.Name)
-			} else {
-				g.Mutex.Unlock()
-				t.Damage <- DamageMessage{z.Holding.Damage(), "ZOMBIE", z.Holding}
-				if z.Holding.Consumable() {
-					z.Holding = Nothing
-				}
-				g.Mutex.Lock()
-			}
-			g.Mutex.Unlock()
-			continue
+	t := z.nearestPersonTraverseFirstStep(g)
+	if t != nil {
+		fortification := 0
+		if len(t.People) > 0 {
+			fortification = t.Weight
 		}
-
-		if z.Holding == Nothing && len(currentNode.Items) > 0 {
-			i := rand.Intn(len(currentNode.Items))
-			if currentNode.Items[i] != Water {
-				z.Holding = currentNode.Items[i]
-				//				g.Log <- fmt.Sprintf("ZOMBIE picked up %s at %s", currentNode.Items[i].StringLong(), currentNode.Name)
-				currentNode.Items = append(currentNode.Items[:i], currentNode.Items[i+1:]...)
-				currentNode.RenderName(g.atlas)
-				g.Mutex.Unlock()
-				continue
-			}
-
+		z.dest = t
+		z.wait = secondsToTicks(g.Edge(currentNode, t).Weight() + float64(fortification)*g.Tunables.FortificationSeconds)
+		if len(t.People) > 0 {
+			g.emit(Event{Kind: BreakIn, Actor: z.actor(), Vertex: currentNode.ID(), To: t.ID(), Value: z.wait})
 		}
-		g.Mutex.Unlock()
-
-		if len(g.Node(z.Location).People) == 0 {
-			t := z.nearestPersonTraverseFirstStep(g)
-			if t != nil {
-				fortification := 0
-				if len(t.People) > 0 {
-					fortification = t.Weight
-					g.Log <- fmt.Sprintf("ZOMBIE is trying to break into %s from %s", t.Name, g.Node(z.Location).Name)
-				}
-				pause(int(g.Edge(g.Node(z.Location), t).Weight())+fortification*2, time.Second)
-				if z.checkKilled(g) {
-					return
-				}
-				if len(t.People) > 0 {
-					g.Log <- fmt.Sprintf("ZOMBIE successfully broke into %s from %s", t.Name, g.Node(z.Location).Name)
-				}
-				z.moveTo(g, t)
-			}
+		if z.wait == 0 {
+			z.arrive(g)
+		}
+	}
+}
+
+func (z *Zombie) takeDamage(g *MapGraph) bool {
+	for _, m := range z.damage {
+		z.Health -= int(m.Value)
+		g.emit(Event{Kind: Attack, Actor: m.Attacker, Target: z.actor(), Vertex: z.Location, To: -1, Item: m.Item, Value: int(m.Value), Health: z.Health})
+		if z.Health <= 0 {
+			z.damage = nil
+			z.kill(g, Killed, &m)
+			return true
 		}
 	}
+	z.damage = z.damage[:0]
+	return false
+}
+
+func (z *Zombie) kill(g *MapGraph, cause Cause, by *DamageMessage) {
+	z.dead = true
+	g.RemoveZombie(z)
+	e := Event{Kind: Death, Actor: z.actor(), Vertex: z.Location, To: -1, Cause: cause}
+	if by != nil {
+		e.Target = by.Attacker
+		e.Item = by.Item
+	}
+	g.emit(e)
+}
+
+func (z *Zombie) arrive(g *MapGraph) {
+	t := z.dest
+	z.dest = nil
+	if z.takeDamage(g) {
+		return
+	}
+	from := z.Location
+	z.moveTo(g, t)
+	g.emit(Event{Kind: Move, Actor: z.actor(), Vertex: from, To: t.ID()})
 }
 
 func (z *Zombie) moveTo(g *MapGraph, t *PositionedNode) {
@@ -312,68 +414,14 @@ func (z *Zombie) moveTo(g *MapGraph, t *PositionedNode) {
 	g.Mutex.Unlock()
 }
 
-// Returns the first vertex on a path towards the nearest person by traversal (Dijkstra's Shortest Path)
+// Returns the first vertex on a path towards the nearest person by traversal, or nil if nobody can be reached
 func (z *Zombie) nearestPersonTraverseFirstStep(g *MapGraph) *PositionedNode {
 	g.Mutex.RLock()
-	unvisited := g.Nodes()
-	distance := make(map[int]uint)
-	previous := make(map[int]int)
-	for _, v := range unvisited {
-		distance[v.ID()] = ^uint(0)
-	}
-
-	distance[z.Location] = 0
-
-	// Find the nearest person
-	var nearestPerson *PositionedNode
-	nearestPersonDist := ^uint(0)
-	for len(unvisited) > 0 {
-		min := ^uint(0) // uint max value
-		var minvI int
-		for i, v := range unvisited {
-			if distance[v.ID()] < min {
-				minvI = i
-			}
-		}
-
-		v := unvisited[minvI]
-		if len(v.People) > 0 && distance[v.ID()] < nearestPersonDist {
-			nearestPerson = v
-			nearestPersonDist = distance[v.ID()]
-		}
-
-		unvisited = append(unvisited[:minvI], unvisited[minvI+1:]...)
-
-		for _, t := range g.From(v) {
-			d := distance[v.ID()] + uint(g.Edge(v, t).Weight())
-			if d < distance[t.ID()] {
-				distance[t.ID()] = d
-				previous[t.ID()] = v.ID()
-			}
-		}
-	}
-
-	// Work back to the first step on that path
-	var ret *PositionedNode
-	if nearestPerson != nil {
-		ret = nearestPerson
-		for previous[ret.ID()] != z.Location {
-			ret = g.Node(previous[ret.ID()])
-		}
-	} else {
-		ret = nil
-	}
-	g.Mutex.RUnlock()
-	return ret
-}
-
-func (z *Zombie) checkKilled(g *MapGraph) bool {
-	select {
-	case reason := <-z.Kill:
-		g.RemoveZombie(z)
-		g.Log <- fmt.Sprintf("ZOMBIE %s at %s", reason, g.Node(z.Location).Name)
-		return true
-	default:
-		return false
+	defer g.Mutex.RUnlock()
+	field := g.humans
+	// Outside of Step, nobody has worked it out yet
+	if field == nil {
+		field = g.distanceField(hasPeople)
 	}
+	return g.downhill(field, g.Node(z.Location))
 }
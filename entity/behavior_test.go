@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"testing"
+)
+
+// 0 - 1 - 2, and 0 - 3 - 4 - 5 the long way round to the same things
+func plannerGraph() *MapGraph {
+	return testGraph(
+		[]Vec{V(0, 0), V(10, 0), V(20, 0), V(0, 10), V(10, 10), V(20, 10)},
+		[]testEdge{{0, 1, 1}, {1, 2, 1}, {0, 3, 1}, {3, 4, 1}, {4, 5, 1}},
+	)
+}
+
+func TestThirstyPersonSeeksWater(t *testing.T) {
+	g := plannerGraph()
+	g.Node(2).Items = []Item{Water}
+	g.Node(5).Items = []Item{Water}
+	p := g.AddPerson(Other, g.Node(0))
+	p.Items = []Item{Pistol}
+
+	if p.need(g) != nil {
+		t.Errorf("someone armed, fed and watered shouldn't need anything")
+	}
+	p.Thirst = 150
+	if s := p.seek(g, p.need(g)); s == nil || s.Id != 1 {
+		t.Errorf("heads for %v, want the nearest water through vertex 1", s)
+	}
+
+	// The way through a zombie is out
+	g.AddNewZombie(g.Node(1))
+	if s := p.seek(g, p.need(g)); s == nil || s.Id != 3 {
+		t.Errorf("heads for %v, want the long way round through vertex 3", s)
+	}
+}
+
+// Once chosen, somewhere stays the goal while it still has what's wanted, even if something turns up nearer
+func TestPlannerKeepsGoal(t *testing.T) {
+	g := plannerGraph()
+	g.Node(5).Items = []Item{WaterBottle}
+	p := g.AddPerson(Other, g.Node(0))
+	p.Items = []Item{Pistol}
+	p.Thirst = 150
+
+	if s := p.seek(g, p.need(g)); s == nil || s.Id != 3 || p.goal != g.Node(5) {
+		t.Errorf("heads for %v, want the bottle at 5 through vertex 3", s)
+	}
+	g.Node(1).Items = []Item{WaterBottle}
+	if s := p.seek(g, p.need(g)); s == nil || s.Id != 3 {
+		t.Errorf("heads for %v, want to keep going through vertex 3", s)
+	}
+
+	// Gone, so it's time to look again
+	g.Node(5).Items = nil
+	if s := p.seek(g, p.need(g)); s == nil || s.Id != 1 || p.goal != g.Node(1) {
+		t.Errorf("heads for %v, want the bottle at 1", s)
+	}
+}
+
+func TestPlannerPriorities(t *testing.T) {
+	g := plannerGraph()
+	p := g.AddPerson(Other, g.Node(0))
+	p.Items = nil
+	p.Hunger, p.Thirst = 150, 150
+
+	wants := p.need(g)
+	if !wants(Water) || wants(EnergyBar) {
+		t.Errorf("someone hungry and thirsty should want water first")
+	}
+	p.Thirst = 0
+	if wants = p.need(g); !wants(EnergyBar) || wants(Water) {
+		t.Errorf("someone hungry should want energy bars")
+	}
+	p.Hunger = 0
+	if wants = p.need(g); !wants(Pistol) || wants(EnergyBar) || wants(Water) {
+		t.Errorf("someone unarmed should want weapons")
+	}
+	p.Items = []Item{Hatchet}
+	if wants = p.need(g); wants != nil {
+		t.Errorf("someone armed, fed and watered shouldn't need anything")
+	}
+}
+
+// Once there, the planner picks up what it came for rather than something at random
+func TestUnarmedPersonPicksUpWeapon(t *testing.T) {
+	g := plannerGraph()
+	g.Node(0).Items = []Item{Bandage, Bandage, EnergyBar, Rifle, Bandage}
+	p := g.AddPerson(Other, g.Node(0))
+	p.Items = nil
+	g.Step()
+	if !p.Holding(Rifle) || len(p.Items) != 1 {
+		t.Errorf("picked up %v, want the rifle", p.Items)
+	}
+}
+
+// Zombies acting later in a tick chase whoever is left, as if each had searched for themselves
+func TestZombiesShareField(t *testing.T) {
+	// 1 - 0 - 2 - 3, with one person to infect at 1, another at 3, and a zombie at each of 1 and 0
+	g := testGraph([]Vec{V(0, 0), V(-10, 0), V(10, 0), V(20, 0)}, []testEdge{{0, 1, 10}, {0, 2, 10}, {2, 3, 10}})
+	victim, other := g.AddPerson(Other, g.Node(1)), g.AddPerson(Other, g.Node(3))
+	victim.Health = 1
+	// Both busy, so only the zombies do anything
+	victim.wait, other.wait = 100, 100
+	infector, chaser := g.AddNewZombie(g.Node(1)), g.AddNewZombie(g.Node(0))
+	infector.Holding = Hatchet
+	infector.wait, chaser.wait = 0, 0
+
+	g.Step()
+	if !victim.dead {
+		t.Fatalf("the first zombie didn't infect anyone")
+	}
+	want := g.NearestPath(g.Node(0), hasPeople).FirstStep()
+	if chaser.dest == nil || chaser.dest != want {
+		t.Errorf("the second zombie heads for %v, want %v, where the nearest person left is", chaser.dest, want)
+	}
+}
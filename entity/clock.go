@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Slowest and fastest the simulation can be run, as multiples of real time
+const (
+	MIN_SPEED = 0.25
+	MAX_SPEED = 16.0
+)
+
+// Simulation time, and how fast it passes relative to real time
+type Clock struct {
+	// Ticks since the start of the simulation
+	Ticks uint64
+
+	Paused bool
+	Speed  float64
+
+	// Scaled real time not yet spent on a tick
+	elapsed time.Duration
+}
+
+func (c *Clock) TogglePause() {
+	c.Paused = !c.Paused
+}
+
+// Double the speed, up to MAX_SPEED
+func (c *Clock) Faster() {
+	c.Speed *= 2
+	if c.Speed > MAX_SPEED {
+		c.Speed = MAX_SPEED
+	}
+}
+
+// Halve the speed, down to MIN_SPEED
+func (c *Clock) Slower() {
+	c.Speed /= 2
+	if c.Speed < MIN_SPEED {
+		c.Speed = MIN_SPEED
+	}
+}
+
+// Simulated time since the start
+func (c *Clock) Time() time.Duration {
+	return time.Duration(c.Ticks) * TickDuration
+}
+
+// Account for real time passing, returning the number of ticks now due
+func (c *Clock) advance(elapsed time.Duration) int {
+	if c.Paused {
+		return 0
+	}
+
+	c.elapsed += time.Duration(float64(elapsed) * c.Speed)
+	n := int(c.elapsed / TickDuration)
+	c.elapsed -= time.Duration(n) * TickDuration
+	return n
+}
+
+func (c *Clock) String() string {
+	if c.Paused {
+		return "PAUSED"
+	}
+	return fmt.Sprintf("%gx", c.Speed)
+}
@@ -0,0 +1,178 @@
+package entity
+
+import (
+	"fmt"
+)
+
+// Everything that happens in the simulation is reported as an Event to each subscriber
+
+type EventKind uint
+
+const (
+	Attack EventKind = iota
+	Infection
+	Death
+	Move
+	Pickup
+	Consume
+	BreakIn
+	Spawn
+	Flee
+	Share
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Attack:
+		return "ATTACK"
+	case Infection:
+		return "INFECTION"
+	case Death:
+		return "DEATH"
+	case Move:
+		return "MOVE"
+	case Pickup:
+		return "PICKUP"
+	case Consume:
+		return "CONSUME"
+	case BreakIn:
+		return "BREAK-IN"
+	case Spawn:
+		return "SPAWN"
+	case Flee:
+		return "FLEE"
+	case Share:
+		return "SHARE"
+	default:
+		return "INVALID EVENT"
+	}
+}
+
+// Kinds are written to recordings by name
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *EventKind) UnmarshalText(text []byte) error {
+	for c := Attack; c <= Share; c++ {
+		if c.String() == string(text) {
+			*k = c
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event kind %q", text)
+}
+
+type Cause uint
+
+const (
+	NoCause Cause = iota
+	Starvation
+	Thirst
+	Killed
+)
+
+func (c Cause) String() string {
+	switch c {
+	case Starvation:
+		return "STARVED to DEATH"
+	case Thirst:
+		return "DIED of THIRST"
+	case Killed:
+		return "KILLED"
+	default:
+		return "DIED"
+	}
+}
+
+// Identifies a person or zombie taking part in an event
+type Actor struct {
+	Id     uint
+	Zombie bool
+	Name   string // Profession, or ZOMBIE
+}
+
+func (p *Person) actor() Actor {
+	return Actor{p.Id, false, p.Profession.String()}
+}
+
+func (z *Zombie) actor() Actor {
+	return Actor{z.Id, true, "ZOMBIE"}
+}
+
+type Event struct {
+	Kind EventKind
+	Time uint64 // Tick on which it happened
+
+	/*
+	** Actor is who did it. Target is who it was done to, for attacks and infections,
+	** or the killer, for deaths, or who was given something, for shares. Either has no Name if nobody was involved,
+	** as for an infection started from the editor, or a death from starvation.
+	 */
+	Actor  Actor
+	Target Actor
+
+	Vertex int // Where it happened
+	To     int // Where the actor is going, for moves, break-ins and flights
+
+	Item   Item  // The weapon used, the item picked up, consumed or shared, or what a new zombie holds
+	Value  int   // Damage dealt by an attack, or ticks a break-in or flight will take
+	Health int   // Health of the target after an attack
+	Cause  Cause // For deaths
+
+	// Everything a new person is carrying, for spawns
+	Items []Item `json:",omitempty"`
+}
+
+// Register a function to be called with every event, in order. Returns a function which unsubscribes it.
+func (g *MapGraph) Subscribe(s func(Event)) func() {
+	g.subscribers = append(g.subscribers, s)
+	i := len(g.subscribers) - 1
+	return func() {
+		g.subscribers[i] = nil
+	}
+}
+
+func (g *MapGraph) emit(e Event) {
+	e.Time = g.Clock.Ticks
+	for _, s := range g.subscribers {
+		if s != nil {
+			s(e)
+		}
+	}
+}
+
+// A human-readable description of an event
+func (g *MapGraph) Describe(e Event) string {
+	at := g.Node(e.Vertex).Name
+	switch e.Kind {
+	case Attack:
+		return fmt.Sprintf("%s at %s takes %d damage from %s wielding %s. Now at %d HP", e.Target.Name, at, e.Value, e.Actor.Name, e.Item.StringLong(), e.Health)
+	case Infection:
+		if e.Actor.Name == "" {
+			return fmt.Sprintf("%s INFECTED at %s", e.Target.Name, at)
+		}
+		return fmt.Sprintf("%s INFECTED %s at %s", e.Actor.Name, e.Target.Name, at)
+	case Death:
+		if e.Cause == Killed {
+			return fmt.Sprintf("%s killed by %s with %s at %s", e.Actor.Name, e.Target.Name, e.Item.StringLong(), at)
+		}
+		return fmt.Sprintf("%s %s at %s", e.Actor.Name, e.Cause, at)
+	case Move:
+		return fmt.Sprintf("%s moves from %s to %s", e.Actor.Name, at, g.Node(e.To).Name)
+	case Pickup:
+		return fmt.Sprintf("%s picked up %s at %s", e.Actor.Name, e.Item.StringLong(), at)
+	case Consume:
+		return fmt.Sprintf("%s used %s at %s", e.Actor.Name, e.Item.StringLong(), at)
+	case BreakIn:
+		return fmt.Sprintf("%s is trying to break into %s from %s", e.Actor.Name, g.Node(e.To).Name, at)
+	case Spawn:
+		return fmt.Sprintf("%s appeared at %s", e.Actor.Name, at)
+	case Flee:
+		return fmt.Sprintf("%s flees from %s towards %s", e.Actor.Name, at, g.Node(e.To).Name)
+	case Share:
+		return fmt.Sprintf("%s gave %s %s at %s", e.Actor.Name, e.Target.Name, e.Item.StringLong(), at)
+	default:
+		return e.Kind.String()
+	}
+}
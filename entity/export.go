@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+** Exporters for drawing the map with other tools. Both carry vertex names, positions, fortification and
+** edge weights, and, if live is set, the number of people, zombies and items on each vertex.
+ */
+
+// Write the map as an undirected Graphviz graph. Positions are pinned, for neato -n.
+func (g *MapGraph) WriteDOT(out io.Writer, live bool) error {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "graph zombies {")
+	fmt.Fprintln(w, "\tnode [shape=circle];")
+	for _, n := range g.Nodes() {
+		fmt.Fprintf(w, "\t%d [label=%s, pos=\"%g,%g!\", fortification=%d", n.Id, dotQuote(n.Name), n.Pos.X, n.Pos.Y, n.Weight)
+		if live {
+			fmt.Fprintf(w, ", people=%d, zombies=%d, items=%d", len(n.People), len(n.Zombies), len(n.Items))
+		}
+		fmt.Fprintln(w, "];")
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(w, "\t%d -- %d [weight=%g, label=\"%g\"];\n", e.From().ID(), e.To().ID(), e.W, e.W)
+	}
+	fmt.Fprintln(w, "}")
+
+	return w.Flush()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+// Write the map as GraphML, with each property as a data key
+func (g *MapGraph) WriteGraphML(out io.Writer, live bool) error {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+
+	keys := [][3]string{
+		{"name", "node", "string"},
+		{"x", "node", "double"},
+		{"y", "node", "double"},
+		{"fortification", "node", "int"},
+		{"weight", "edge", "double"},
+	}
+	if live {
+		keys = append(keys, [3]string{"people", "node", "int"}, [3]string{"zombies", "node", "int"}, [3]string{"items", "node", "int"})
+	}
+	for _, k := range keys {
+		fmt.Fprintf(w, "\t<key id=\"%s\" for=\"%s\" attr.name=\"%s\" attr.type=\"%s\"/>\n", k[0], k[1], k[0], k[2])
+	}
+
+	fmt.Fprintln(w, `	<graph id="zombies" edgedefault="undirected">`)
+	for _, n := range g.Nodes() {
+		fmt.Fprintf(w, "\t\t<node id=\"n%d\">\n", n.Id)
+		fmt.Fprint(w, "\t\t\t<data key=\"name\">")
+		xml.EscapeText(w, []byte(n.Name))
+		fmt.Fprintln(w, "</data>")
+		fmt.Fprintf(w, "\t\t\t<data key=\"x\">%g</data>\n", n.Pos.X)
+		fmt.Fprintf(w, "\t\t\t<data key=\"y\">%g</data>\n", n.Pos.Y)
+		fmt.Fprintf(w, "\t\t\t<data key=\"fortification\">%d</data>\n", n.Weight)
+		if live {
+			fmt.Fprintf(w, "\t\t\t<data key=\"people\">%d</data>\n", len(n.People))
+			fmt.Fprintf(w, "\t\t\t<data key=\"zombies\">%d</data>\n", len(n.Zombies))
+			fmt.Fprintf(w, "\t\t\t<data key=\"items\">%d</data>\n", len(n.Items))
+		}
+		fmt.Fprintln(w, "\t\t</node>")
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(w, "\t\t<edge source=\"n%d\" target=\"n%d\">\n", e.From().ID(), e.To().ID())
+		fmt.Fprintf(w, "\t\t\t<data key=\"weight\">%g</data>\n", e.W)
+		fmt.Fprintln(w, "\t\t</edge>")
+	}
+	fmt.Fprintln(w, "\t</graph>")
+	fmt.Fprintln(w, "</graphml>")
+
+	return w.Flush()
+}
@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"container/heap"
+	"math"
+)
+
+/*
+** Distance fields. One multi-source search gives the distance from every vertex to the nearest vertex satisfying
+** some goal, and anyone wanting to get to one of those just walks downhill. Zombies share one per tick, towards
+** people, instead of each searching for themselves.
+ */
+
+// Distance from each vertex to the nearest satisfying goal. Vertices which can't reach one are left out. Needs at least the read lock.
+func (g *MapGraph) distanceField(goal func(*PositionedNode) bool) map[int]float64 {
+	distance := make(map[int]float64)
+	done := make(map[int]bool)
+	q := &pathQueue{}
+	for _, v := range g.Nodes() {
+		if goal(v) {
+			distance[v.Id] = 0
+			*q = append(*q, pathItem{v, 0})
+		}
+	}
+	heap.Init(q)
+
+	for q.Len() > 0 {
+		v := heap.Pop(q).(pathItem).v
+		if done[v.Id] {
+			continue
+		}
+		done[v.Id] = true
+		for _, t := range g.Neighbors(v) {
+			d := distance[v.Id] + g.Edge(v, t).Weight()
+			if old, ok := distance[t.Id]; !done[t.Id] && (!ok || d < old) {
+				distance[t.Id] = d
+				heap.Push(q, pathItem{t, d})
+			}
+		}
+	}
+
+	return distance
+}
+
+// The neighbour of v which is furthest downhill, or nil if v is already at the bottom or can't reach it. Needs at least the read lock.
+func (g *MapGraph) downhill(field map[int]float64, v *PositionedNode) *PositionedNode {
+	here, ok := field[v.Id]
+	if !ok || here == 0 {
+		return nil
+	}
+	var ret *PositionedNode
+	best := math.Inf(1)
+	// Neighbours come in order of ID, so ties go to the lowest
+	for _, t := range g.Neighbors(v) {
+		if d, ok := field[t.Id]; ok && d+g.Edge(v, t).Weight() < best {
+			ret = t
+			best = d + g.Edge(v, t).Weight()
+		}
+	}
+	return ret
+}
+
+func hasPeople(v *PositionedNode) bool {
+	return len(v.People) > 0
+}
+
+// Work out where everyone is, for the zombies' turn. RemovePerson keeps it up to date as people are infected.
+func (g *MapGraph) updateHumanField() {
+	g.Mutex.RLock()
+	g.humans = g.distanceField(hasPeople)
+	g.Mutex.RUnlock()
+}
@@ -0,0 +1,110 @@
+package entity
+
+import (
+	"container/heap"
+	"math"
+)
+
+/*
+** Getting away from zombies. People look for the safest vertex they can get to before any zombie could,
+** where safety is how long the nearest zombie would take to get in: walking there, then breaking through
+** the fortification. They only go through vertices they'd also reach first, so they never run into zombies
+** on the way.
+ */
+
+func hasZombies(v *PositionedNode) bool {
+	return len(v.Zombies) > 0
+}
+
+// How long the nearest zombie would take to get at someone on v, given the distance to the nearest zombie from everywhere
+func (g *MapGraph) safety(threats map[int]float64, v *PositionedNode) float64 {
+	d, ok := threats[v.Id]
+	if !ok {
+		return math.Inf(1)
+	}
+	if d == 0 {
+		return 0
+	}
+	return d + float64(v.Weight)*g.Tunables.FortificationSeconds
+}
+
+// The first step towards the safest vertex reachable from from, or nil if from is as safe as it gets. Needs at least the read lock.
+func (g *MapGraph) fleeStep(threats map[int]float64, from *PositionedNode) *PositionedNode {
+	// Zombies already here start level with anyone running, so only zombies elsewhere can cut them off
+	chasers := threats
+	if hasZombies(from) {
+		chasers = g.distanceField(func(v *PositionedNode) bool {
+			return v != from && hasZombies(v)
+		})
+	}
+	zombieDistance := func(v *PositionedNode) float64 {
+		if d, ok := chasers[v.Id]; ok {
+			return d
+		}
+		return math.Inf(1)
+	}
+
+	distance := map[int]float64{from.Id: 0}
+	previous := make(map[int]int)
+	done := make(map[int]bool)
+	q := &pathQueue{{from, 0}}
+
+	best, bestSafety := from, g.safety(threats, from)
+	for q.Len() > 0 {
+		v := heap.Pop(q).(pathItem).v
+		if done[v.Id] {
+			continue
+		}
+		done[v.Id] = true
+		// Popped in order of distance and then ID, so ties go to the nearest
+		if s := g.safety(threats, v); s > bestSafety {
+			best, bestSafety = v, s
+		}
+
+		for _, t := range g.Neighbors(v) {
+			d := distance[v.Id] + g.Edge(v, t).Weight()
+			// Only anywhere they'd get to before a zombie from elsewhere could
+			if d >= zombieDistance(t) {
+				continue
+			}
+			if old, ok := distance[t.Id]; !done[t.Id] && (!ok || d < old) {
+				distance[t.Id] = d
+				previous[t.Id] = v.Id
+				heap.Push(q, pathItem{t, d})
+			}
+		}
+	}
+
+	if best == from {
+		return nil
+	}
+	return tracePath(g, previous, from, best, distance[best.Id]).FirstStep()
+}
+
+// Work out where the zombies are, for the people's turn
+func (g *MapGraph) updateThreatField() {
+	g.Mutex.RLock()
+	g.threats = g.distanceField(hasZombies)
+	g.Mutex.RUnlock()
+}
+
+// Where to run to, if there are zombies here or next door and anywhere safer to go
+func (p *Person) fleeStep(g *MapGraph) *PositionedNode {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	current := g.Node(p.Location)
+	threatened := hasZombies(current)
+	for _, t := range g.Neighbors(current) {
+		threatened = threatened || hasZombies(t)
+	}
+	if !threatened {
+		return nil
+	}
+
+	threats := g.threats
+	// Outside of Step, nobody has worked it out yet
+	if threats == nil {
+		threats = g.distanceField(hasZombies)
+	}
+	return g.fleeStep(threats, current)
+}
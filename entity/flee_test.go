@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"testing"
+)
+
+func TestFleeAwayFromZombies(t *testing.T) {
+	// 0 - 1 - 2 - 3, with a zombie at 0 and a person at 1
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(20, 0), V(30, 0)}, []testEdge{{0, 1, 1}, {1, 2, 1}, {2, 3, 1}})
+	p := g.AddPerson(Other, g.Node(1))
+	g.AddNewZombie(g.Node(0))
+	if s := p.fleeStep(g); s == nil || s.Id != 2 {
+		t.Errorf("flees to %v, want vertex 2", s)
+	}
+}
+
+func TestFleeOnlyWhenThreatened(t *testing.T) {
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(20, 0), V(30, 0)}, []testEdge{{0, 1, 1}, {1, 2, 1}, {2, 3, 1}})
+	p := g.AddPerson(Other, g.Node(3))
+	g.AddNewZombie(g.Node(0))
+	if s := p.fleeStep(g); s != nil {
+		t.Errorf("flees to %s with no zombies nearby", s.Name)
+	}
+}
+
+// A fortified vertex is worth running to, even if it's no further from the zombies
+func TestFleeToFortification(t *testing.T) {
+	// 1 and 2 are both next to 0, where the person is, and 3, where the zombie is. 2 is fortified.
+	g := testGraph([]Vec{V(0, 0), V(10, 10), V(10, -10), V(20, 0)}, []testEdge{{0, 1, 1}, {0, 2, 1}, {1, 3, 2}, {2, 3, 2}, {0, 3, 1}})
+	g.Node(2).Weight = 3
+	p := g.AddPerson(Other, g.Node(0))
+	g.AddNewZombie(g.Node(3))
+	if s := p.fleeStep(g); s == nil || s.Id != 2 {
+		t.Errorf("flees to %v, want the fortified vertex 2", s)
+	}
+
+	// Staying put is best once here is fortified more
+	g.Node(0).Weight = 10
+	if s := p.fleeStep(g); s != nil {
+		t.Errorf("flees to %s from somewhere safer", s.Name)
+	}
+}
+
+// Zombies on the same vertex don't stop anyone getting away, but zombies elsewhere still cut routes off
+func TestFleeFromOwnVertex(t *testing.T) {
+	// 0 - 1 - 2 - 3, with the person and a zombie at 1, and 2 fortified
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(20, 0), V(30, 0)}, []testEdge{{0, 1, 1}, {1, 2, 1}, {2, 3, 1}})
+	g.Node(2).Weight = 10
+	p := g.AddPerson(Other, g.Node(1))
+	p.Items = nil
+	g.AddNewZombie(g.Node(1))
+	if s := p.fleeStep(g); s == nil || s.Id != 2 {
+		t.Errorf("flees to %v, want the fortified vertex 2", s)
+	}
+
+	// A zombie at 3 would get to 2 first
+	g.AddNewZombie(g.Node(3))
+	if s := p.fleeStep(g); s == nil || s.Id != 0 {
+		t.Errorf("flees to %v, want vertex 0", s)
+	}
+
+	// Nothing to fight with, so they run rather than stand
+	g.Step()
+	if p.dest != g.Node(0) {
+		t.Errorf("unarmed person is heading for %v, want vertex 0", p.dest)
+	}
+}
+
+// Nobody runs past a zombie, or somewhere a zombie would get to first
+func TestFleeNotThroughZombies(t *testing.T) {
+	// 2 - 0 - 1 - 3 - 4, with a zombie at 1 next to the person at 0. Everything past 1 is further away, but 2 is the only way out.
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(-10, 0), V(20, 0), V(30, 0)}, []testEdge{{0, 1, 1}, {0, 2, 1}, {1, 3, 1}, {3, 4, 1}})
+	p := g.AddPerson(Other, g.Node(0))
+	g.AddNewZombie(g.Node(1))
+	if s := p.fleeStep(g); s == nil || s.Id != 2 {
+		t.Errorf("flees to %v, want vertex 2", s)
+	}
+}
+
+func TestFleeEvent(t *testing.T) {
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(20, 0)}, []testEdge{{0, 1, 1}, {1, 2, 1}})
+	p := g.AddPerson(Other, g.Node(1))
+	p.Items = nil
+	g.AddNewZombie(g.Node(0))
+
+	var flights []Event
+	g.Subscribe(func(e Event) {
+		if e.Kind == Flee {
+			flights = append(flights, e)
+		}
+	})
+	g.Step()
+	if len(flights) != 1 || flights[0].Actor.Id != p.Id || flights[0].Vertex != 1 || flights[0].To != 2 {
+		t.Errorf("got flee events %+v, want one from 1 to 2", flights)
+	}
+
+	var k EventKind
+	if err := k.UnmarshalText([]byte(Flee.String())); err != nil || k != Flee {
+		t.Errorf("flee events don't survive a recording: %v", err)
+	}
+}
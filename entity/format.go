@@ -0,0 +1,273 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gonum/graph/simple"
+)
+
+/*
+** The map file format. Maps are JSON, with the following layout (version 1):
+**
+** {
+**     "version": 1,
+**     "bounds": {"min": {"x": 0, "y": 0}, "max": {"x": 1000, "y": 1000}},
+**     "vertexSize": 25,
+**     "ticks": 0,
+**     "nodes": [
+**         {
+**             "id": 0,
+**             "name": "Church",
+**             "pos": {"x": 500, "y": 500},
+**             "fortification": 2,
+**             "items": [14, 14],
+**             "people": [{"id": 3, "profession": 5, "health": 100, "hunger": 0, "thirst": 0, "items": [5], "leader": 3}],
+**             "zombies": [{"id": 7, "health": 100, "hunger": 0, "holding": 6}]
+**         }
+**     ],
+**     "edges": [{"from": 0, "to": 1, "weight": 3}]
+** }
+**
+** Items and professions are given by number (see Item and Profession).
+** ticks is only present for maps saved part way through a simulation.
+** A zombie without "holding" is holding nothing. A person's "location" may be given,
+** in which case it must match the vertex holding them. "leader" is the ID of the leader of the person's
+** squad, if they're in one, which is on the same vertex (and is their own ID, for the leader).
+**
+** Files without a version are the original format, {"G": {...}, "U": {"Nodes": [...], "Edges": [...]}},
+** which is migrated on load.
+ */
+
+const MapVersion = 1
+
+type MapFile struct {
+	Version    int        `json:"version"`
+	Bounds     Rect       `json:"bounds"`
+	VertexSize float64    `json:"vertexSize,omitempty"`
+	Ticks      uint64     `json:"ticks,omitempty"`
+	Nodes      []NodeFile `json:"nodes"`
+	Edges      []EdgeFile `json:"edges"`
+}
+
+type NodeFile struct {
+	Id            int          `json:"id"`
+	Name          string       `json:"name"`
+	Pos           Vec          `json:"pos"`
+	Fortification int          `json:"fortification"`
+	Items         []Item       `json:"items,omitempty"`
+	People        []PersonFile `json:"people,omitempty"`
+	Zombies       []ZombieFile `json:"zombies,omitempty"`
+}
+
+type PersonFile struct {
+	Id         uint       `json:"id"`
+	Profession Profession `json:"profession"`
+	Health     int        `json:"health"`
+	Hunger     uint       `json:"hunger"`
+	Thirst     uint       `json:"thirst"`
+	Items      []Item     `json:"items,omitempty"`
+	Location   *int       `json:"location,omitempty"`
+	Leader     *uint      `json:"leader,omitempty"`
+}
+
+type ZombieFile struct {
+	Id      uint  `json:"id"`
+	Health  int   `json:"health"`
+	Hunger  int   `json:"hunger"`
+	Holding *Item `json:"holding,omitempty"`
+}
+
+type EdgeFile struct {
+	From   int     `json:"from"`
+	To     int     `json:"to"`
+	Weight float64 `json:"weight"`
+}
+
+// Parse a map file of any version, migrating it to the current one
+func ParseMapFile(data []byte) (*MapFile, error) {
+	var v struct {
+		Version int `json:"version"`
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.Version {
+	case 0:
+		return migrateV0(data)
+	case MapVersion:
+		f := new(MapFile)
+		err = json.Unmarshal(data, f)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("unsupported map version %d (newest supported is %d)", v.Version, MapVersion)
+	}
+}
+
+// The original, unversioned format, which was a dump of the graph's internals
+func migrateV0(data []byte) (*MapFile, error) {
+	var old struct {
+		G struct {
+			Bounds     Rect
+			VertexSize float64
+		}
+		U struct {
+			Nodes []struct {
+				Id      int
+				Name    string
+				Weight  int
+				Items   []Item
+				People  []Person
+				Zombies []Zombie
+				Pos     Vec
+			}
+			Edges []struct {
+				F, T int
+				W    float64
+			}
+		}
+	}
+	err := json.Unmarshal(data, &old)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &MapFile{Version: MapVersion, Bounds: old.G.Bounds, VertexSize: old.G.VertexSize}
+	for _, n := range old.U.Nodes {
+		nf := NodeFile{Id: n.Id, Name: n.Name, Pos: n.Pos, Fortification: n.Weight, Items: n.Items}
+		for _, p := range n.People {
+			location := p.Location
+			nf.People = append(nf.People, PersonFile{p.Id, p.Profession, p.Health, p.Hunger, p.Thirst, p.Items, &location, nil})
+		}
+		for _, z := range n.Zombies {
+			nf.Zombies = append(nf.Zombies, newZombieFile(&z))
+		}
+		f.Nodes = append(f.Nodes, nf)
+	}
+	for _, e := range old.U.Edges {
+		f.Edges = append(f.Edges, EdgeFile{e.F, e.T, e.W})
+	}
+
+	return f, nil
+}
+
+func newZombieFile(z *Zombie) ZombieFile {
+	zf := ZombieFile{Id: z.Id, Health: z.Health, Hunger: z.Hunger}
+	if z.Holding != Nothing {
+		holding := z.Holding
+		zf.Holding = &holding
+	}
+	return zf
+}
+
+// The map as it currently stands, in the current file format
+func (g *MapGraph) MapFile() *MapFile {
+	f := &MapFile{Version: MapVersion, Bounds: g.Bounds, VertexSize: g.VertexSize, Ticks: g.Clock.Ticks}
+	for _, n := range g.Nodes() {
+		nf := NodeFile{Id: n.Id, Name: n.Name, Pos: n.Pos, Fortification: n.Weight, Items: n.Items}
+		for _, p := range n.People {
+			pf := PersonFile{p.Id, p.Profession, p.Health, p.Hunger, p.Thirst, p.Items, nil, nil}
+			if p.squad != nil {
+				leader := p.squad.Leader.Id
+				pf.Leader = &leader
+			}
+			nf.People = append(nf.People, pf)
+		}
+		for _, z := range n.Zombies {
+			nf.Zombies = append(nf.Zombies, newZombieFile(z))
+		}
+		f.Nodes = append(f.Nodes, nf)
+	}
+
+	for _, e := range g.UndirectedGraph.Edges() {
+		ef := EdgeFile{e.From().ID(), e.To().ID(), e.Weight()}
+		if ef.From > ef.To {
+			ef.From, ef.To = ef.To, ef.From
+		}
+		f.Edges = append(f.Edges, ef)
+	}
+	sort.Slice(f.Edges, func(i, j int) bool {
+		if f.Edges[i].From != f.Edges[j].From {
+			return f.Edges[i].From < f.Edges[j].From
+		}
+		return f.Edges[i].To < f.Edges[j].To
+	})
+
+	return f
+}
+
+// Add everything in a map file to the graph
+func (g *MapGraph) Load(f *MapFile) error {
+	g.Bounds = f.Bounds
+	if f.VertexSize > 0 {
+		g.VertexSize = f.VertexSize
+	}
+	g.Clock.Ticks = f.Ticks
+
+	leaders := make(map[uint]uint)
+	for _, nf := range f.Nodes {
+		if g.Has(simple.Node(nf.Id)) {
+			return fmt.Errorf("duplicate vertex ID %d (%s)", nf.Id, nf.Name)
+		}
+
+		n := &PositionedNode{nf.Id, nf.Name, nf.Fortification, make([]*Person, 0, len(nf.People)), make([]*Zombie, 0, len(nf.Zombies)), nf.Items, nf.Pos}
+		for _, pf := range nf.People {
+			n.People = append(n.People, &Person{Id: pf.Id, Health: pf.Health, Hunger: pf.Hunger, Thirst: pf.Thirst, Items: pf.Items, Profession: pf.Profession, Location: n.Id})
+			if pf.Leader != nil {
+				leaders[pf.Id] = *pf.Leader
+			}
+			// Don't hand out IDs already used in the file
+			if pf.Id >= g.entities {
+				g.entities = pf.Id + 1
+			}
+		}
+		for _, zf := range nf.Zombies {
+			z := NewZombie(zf.Id, n.Id)
+			z.Health = zf.Health
+			z.Hunger = zf.Hunger
+			if zf.Holding != nil {
+				z.Holding = *zf.Holding
+			}
+			n.Zombies = append(n.Zombies, z)
+			if zf.Id >= g.entities {
+				g.entities = zf.Id + 1
+			}
+		}
+		g.AddNode(n)
+	}
+
+	for _, ef := range f.Edges {
+		if !g.Has(simple.Node(ef.From)) || !g.Has(simple.Node(ef.To)) {
+			return fmt.Errorf("edge from %d to %d refers to a vertex which doesn't exist", ef.From, ef.To)
+		}
+		if ef.From == ef.To {
+			return fmt.Errorf("edge from %d to itself", ef.From)
+		}
+
+		if !g.HasEdgeBetween(g.Node(ef.From), g.Node(ef.To)) {
+			g.SetEdge(&simple.Edge{F: g.Node(ef.From), T: g.Node(ef.To), W: ef.Weight})
+		}
+	}
+	g.loadSquads(leaders)
+
+	return nil
+}
+
+func (g *MapGraph) Serialize() ([]byte, error) {
+	return json.Marshal(g.MapFile())
+}
+
+// Load a map file of any version into the graph
+func (g *MapGraph) Deserialize(data []byte) error {
+	f, err := ParseMapFile(data)
+	if err != nil {
+		return err
+	}
+	return g.Load(f)
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMigrateV0(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/v0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewMapGraph(R(0, 0, 1000, 1000), 25)
+	if err = g.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(g.Nodes()); n != 3 {
+		t.Errorf("got %d vertices, want 3", n)
+	}
+	if n := len(g.Edges()); n != 2 {
+		t.Errorf("got %d edges, want 2", n)
+	}
+	items := 0
+	for _, v := range g.Nodes() {
+		items += len(v.Items)
+	}
+	if items != 3 {
+		t.Errorf("got %d items on the map, want 3", items)
+	}
+	if g.VertexSize != 22.5 || g.Bounds != R(0, 0, 1000, 800) {
+		t.Errorf("got bounds %v and vertex size %v, want the map's", g.Bounds, g.VertexSize)
+	}
+	church, store := g.GetVertexByName("Church"), g.GetVertexByName("Store")
+	if church.Weight != 2 || len(church.People) != 1 || church.People[0].Profession != Police || !church.People[0].Holding(Pistol) {
+		t.Errorf("got %+v at the church, want a police officer with a pistol", church.People)
+	}
+	if len(store.Zombies) != 1 || store.Zombies[0].Holding != Hatchet || store.Zombies[0].Health != 80 {
+		t.Errorf("got %+v at the store, want a zombie with a hatchet", store.Zombies)
+	}
+	if e := g.Edge(g.Node(1), g.Node(2)); e == nil || e.Weight() != 1 {
+		t.Errorf("got edge %v between the store and the hut, want weight 1", e)
+	}
+
+	// Saved in the current format, it loads back the same
+	saved, err := g.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewMapGraph(R(0, 0, 1000, 1000), 25)
+	if err = r.Deserialize(saved); err != nil {
+		t.Fatal(err)
+	}
+	again, err := r.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, again) {
+		t.Errorf("the migrated map changed on a round trip:\n%s\n%s", saved, again)
+	}
+	if f := r.MapFile(); f.Version != MapVersion || len(f.Validate()) > 0 {
+		t.Errorf("the migrated map saves as version %d with problems %v", f.Version, f.Validate())
+	}
+}
@@ -0,0 +1,322 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+/*
+** Procedural town maps. Vertices are scattered over the bounds keeping a minimum distance apart, and given building
+** types. They are joined by a minimum spanning tree, so the map is connected, and then by more short edges which
+** don't cross any already placed, so it stays planar and looks like a road network.
+ */
+
+// How much space each vertex gets, on average. About what the hand-built map has.
+const genSpacing = 336.0
+
+type stock struct {
+	item Item
+	max  int // Each building gets between none and this many
+}
+
+type buildingType struct {
+	name          string
+	fortification int
+	per           int  // One for every this many vertices (at least one if there's room), or 0 to fill the rest
+	share         int  // How common it is among those filling the rest
+	water         bool // Has a water source
+	stock         []stock
+}
+
+// In order of importance, so that small maps get the essentials first
+var buildingTypes = []buildingType{
+	{"Water Treatment Plant", 3, 200, 0, true, nil},
+	{"Police Station", 5, 100, 0, false, []stock{{Pistol, 3}, {Rifle, 3}}},
+	{"Doctor's Office", 3, 100, 0, false, []stock{{Bandage, 9}, {Hacksaw, 1}, {WaterBottle, 2}}},
+	{"General Store", 3, 60, 0, false, []stock{{EnergyBar, 4}, {WaterBottle, 4}, {RustyPipe, 1}, {Wrench, 1}}},
+	{"Fire Station", 3, 100, 0, false, []stock{{Hatchet, 3}, {Chainsaw, 1}, {EnergyBar, 2}}},
+	{"Hardware Store", 3, 100, 0, false, []stock{{Hatchet, 4}, {Wrench, 2}, {Hacksaw, 2}, {Chainsaw, 1}}},
+	{"Church", 2, 100, 0, false, []stock{{HolyWater, 20}}},
+	{"Public Restroom", 1, 30, 0, true, nil},
+	{"Gas Station", 0, 50, 0, false, []stock{{AerosolFlamethrower, 6}, {EnergyBar, 2}}},
+	{"Convenience Store", 3, 50, 0, false, []stock{{EnergyBar, 6}, {WaterBottle, 6}}},
+	{"Store", 3, 20, 0, false, []stock{{EnergyBar, 5}, {WaterBottle, 4}, {Wrench, 1}}},
+	{"Restaurant", 3, 30, 0, false, []stock{{EnergyBar, 10}, {WaterBottle, 10}}},
+	{"Garage", 2, 60, 0, false, []stock{{Wrench, 2}, {Hacksaw, 1}}},
+	{"Warehouse", 2, 25, 0, false, []stock{{Hatchet, 3}, {Wrench, 2}, {Hacksaw, 2}, {RustyPipe, 3}}},
+	{"Park", 0, 30, 0, true, nil},
+	{"Office", 3, 25, 0, false, []stock{{WaterBottle, 1}}},
+	{"House", 4, 0, 3, false, []stock{{EnergyBar, 2}, {WaterBottle, 2}, {RustyPipe, 1}}},
+	{"Trailer", 2, 0, 2, false, []stock{{EnergyBar, 1}, {WaterBottle, 1}, {Wrench, 1}}},
+}
+
+type GenOptions struct {
+	Vertices int
+	Bounds   Rect // Sized to fit the vertices if empty
+	Water    int  // Water sources to have at least, besides the buildings which always have one
+}
+
+// Bounds with about the spacing of the hand-built map
+func genBounds(vertices int) Rect {
+	area := float64(vertices) * genSpacing * genSpacing
+	// The same shape as the hand-built map
+	w := math.Sqrt(area * 3 / 2)
+	return R(0, 0, math.Ceil(w), math.Ceil(area/w))
+}
+
+// The building type of each vertex, with those which fill the rest shuffled in among the others
+func genTypes(n int, rng *rand.Rand) []*buildingType {
+	var ret []*buildingType
+	var fill []*buildingType
+	shares := 0
+	for i := range buildingTypes {
+		t := &buildingTypes[i]
+		if t.per == 0 {
+			fill = append(fill, t)
+			shares += t.share
+			continue
+		}
+		count := n / t.per
+		if count == 0 {
+			count = 1
+		}
+		for ; count > 0 && len(ret) < n; count-- {
+			ret = append(ret, t)
+		}
+	}
+	// Leave some room for houses on small maps
+	if len(ret) > n*3/4 && n >= 4 {
+		ret = ret[:n*3/4]
+	}
+	for len(ret) < n {
+		r := rng.Intn(shares)
+		for _, t := range fill {
+			if r < t.share {
+				ret = append(ret, t)
+				break
+			}
+			r -= t.share
+		}
+	}
+	rng.Shuffle(len(ret), func(i, j int) { ret[i], ret[j] = ret[j], ret[i] })
+	return ret
+}
+
+// Scatter points over the bounds, at least some distance apart, giving up on the distance bit by bit if they won't fit
+func genPositions(n int, bounds Rect, rng *rand.Rand) []Vec {
+	margin := math.Min(bounds.W(), bounds.H()) / 20
+	inner := R(bounds.Min.X+margin, bounds.Min.Y+margin, bounds.Max.X-margin, bounds.Max.Y-margin)
+	apart := math.Sqrt(inner.W()*inner.H()/float64(n)) * 0.6
+
+	ret := make([]Vec, 0, n)
+	for failures := 0; len(ret) < n; {
+		p := V(inner.Min.X+rng.Float64()*inner.W(), inner.Min.Y+rng.Float64()*inner.H())
+		ok := true
+		for _, q := range ret {
+			if p.Dist(q) < apart {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			ret = append(ret, p)
+			continue
+		}
+		failures++
+		if failures > 30 {
+			apart *= 0.9
+			failures = 0
+		}
+	}
+	return ret
+}
+
+// Whether segments ab and cd cross, other than at a shared end
+func segmentsCross(a, b, c, d Vec) bool {
+	if a == c || a == d || b == c || b == d {
+		return false
+	}
+	orient := func(p, q, r Vec) float64 {
+		return (q.X-p.X)*(r.Y-p.Y) - (q.Y-p.Y)*(r.X-p.X)
+	}
+	d1, d2 := orient(c, d, a), orient(c, d, b)
+	d3, d4 := orient(a, b, c), orient(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// Distance from p to the segment ab
+func segmentDist(p, a, b Vec) float64 {
+	ab := b.Sub(a)
+	l := ab.X*ab.X + ab.Y*ab.Y
+	if l == 0 {
+		return p.Dist(a)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*ab.X+(p.Y-a.Y)*ab.Y)/l))
+	return p.Dist(V(a.X+t*ab.X, a.Y+t*ab.Y))
+}
+
+// The roads between positions, as pairs of indices. Always connected and planar.
+func genRoads(pos []Vec) [][2]int {
+	n := len(pos)
+	var roads [][2]int
+	if n < 2 {
+		return roads
+	}
+
+	// Prim's algorithm, which is fine at the sizes we want and needs no candidate edges
+	inTree := make([]bool, n)
+	best := make([]float64, n)
+	from := make([]int, n)
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	best[0] = 0
+	for added := 0; added < n; added++ {
+		u := -1
+		for i := 0; i < n; i++ {
+			if !inTree[i] && (u < 0 || best[i] < best[u]) {
+				u = i
+			}
+		}
+		inTree[u] = true
+		if added > 0 {
+			roads = append(roads, [2]int{from[u], u})
+		}
+		for i := 0; i < n; i++ {
+			if d := pos[u].Dist(pos[i]); !inTree[i] && d < best[i] {
+				best[i], from[i] = d, u
+			}
+		}
+	}
+
+	// Then short cross streets, nearest first. A tree alone would give every pair of vertices one route.
+	const neighbours, maxDegree = 5, 4
+	var longest float64
+	degree := make([]int, n)
+	connected := make(map[[2]int]bool)
+	for _, r := range roads {
+		degree[r[0]]++
+		degree[r[1]]++
+		connected[[2]int{r[0], r[1]}], connected[[2]int{r[1], r[0]}] = true, true
+		longest = math.Max(longest, pos[r[0]].Dist(pos[r[1]]))
+	}
+	var candidates [][2]int
+	for i := range pos {
+		nearest := make([]int, 0, n-1)
+		for j := range pos {
+			if j != i {
+				nearest = append(nearest, j)
+			}
+		}
+		sort.Slice(nearest, func(a, b int) bool { return pos[i].Dist(pos[nearest[a]]) < pos[i].Dist(pos[nearest[b]]) })
+		if len(nearest) > neighbours {
+			nearest = nearest[:neighbours]
+		}
+		// A pair near each other both ways is tried twice, which does no harm
+		for _, j := range nearest {
+			if i < j {
+				candidates = append(candidates, [2]int{i, j})
+			} else {
+				candidates = append(candidates, [2]int{j, i})
+			}
+		}
+	}
+	length := func(c [2]int) float64 {
+		return pos[c[0]].Dist(pos[c[1]])
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return length(candidates[a]) < length(candidates[b]) })
+
+	for _, c := range candidates {
+		a, b := c[0], c[1]
+		if connected[c] || degree[a] >= maxDegree || degree[b] >= maxDegree || length(c) > longest {
+			continue
+		}
+		ok := true
+		for _, r := range roads {
+			if segmentsCross(pos[a], pos[b], pos[r[0]], pos[r[1]]) {
+				ok = false
+				break
+			}
+		}
+		// Don't run a road straight through a building
+		for i := 0; ok && i < n; i++ {
+			if i != a && i != b && segmentDist(pos[i], pos[a], pos[b]) < length(c)/6 {
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+		roads = append(roads, c)
+		degree[a]++
+		degree[b]++
+		connected[[2]int{a, b}], connected[[2]int{b, a}] = true, true
+	}
+
+	return roads
+}
+
+// Generate a connected town map. The same options and seed always give the same map.
+func GenerateMap(o GenOptions, seed int64) (*MapGraph, error) {
+	if o.Vertices < 1 {
+		return nil, fmt.Errorf("a map needs at least one vertex, not %d", o.Vertices)
+	}
+	bounds := o.Bounds
+	if bounds.W() <= 0 || bounds.H() <= 0 {
+		bounds = genBounds(o.Vertices)
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	types := genTypes(o.Vertices, rng)
+	pos := genPositions(o.Vertices, bounds, rng)
+	spacing := math.Sqrt(bounds.W() * bounds.H() / float64(o.Vertices))
+	g := NewMapGraph(bounds, spacing/15)
+
+	// Buildings of a type which has several are numbered, like House 12
+	total := make(map[string]int)
+	for _, t := range types {
+		total[t.name]++
+	}
+	numbered := make(map[string]int)
+	vertices := make([]*PositionedNode, o.Vertices)
+	water := 0
+	for i, t := range types {
+		name := t.name
+		if total[t.name] > 1 {
+			numbered[t.name]++
+			name = fmt.Sprintf("%s %d", t.name, numbered[t.name])
+		}
+		v := g.NewPositionedNode(name, pos[i].X, pos[i].Y, t.fortification)
+		if t.water {
+			v.Items = append(v.Items, Water)
+			water++
+		}
+		for _, s := range t.stock {
+			for c := rng.Intn(s.max + 1); c > 0; c-- {
+				v.Items = append(v.Items, s.item)
+			}
+		}
+		g.AddNode(v)
+		vertices[i] = v
+	}
+
+	// Wells, ponds and the like, so there's water around the whole map
+	for _, i := range rng.Perm(o.Vertices) {
+		if water >= o.Water {
+			break
+		}
+		if !vertices[i].ItemPresent(Water) {
+			vertices[i].Items = append(vertices[i].Items, Water)
+			water++
+		}
+	}
+
+	// Most roads take a second to walk, and longer ones more
+	for _, r := range genRoads(pos) {
+		g.AddEdge(vertices[r[0]], vertices[r[1]], math.Max(1, math.Round(pos[r[0]].Dist(pos[r[1]])/spacing)))
+	}
+
+	return g, nil
+}
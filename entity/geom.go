@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"math"
+)
+
+// Minimal 2D geometry, so that the simulation doesn't depend on a graphics library.
+
+type Vec struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func V(x float64, y float64) Vec {
+	return Vec{x, y}
+}
+
+type Rect struct {
+	Min Vec `json:"min"`
+	Max Vec `json:"max"`
+}
+
+func R(minX float64, minY float64, maxX float64, maxY float64) Rect {
+	return Rect{Vec{minX, minY}, Vec{maxX, maxY}}
+}
+
+func (r Rect) W() float64 {
+	return r.Max.X - r.Min.X
+}
+
+func (r Rect) H() float64 {
+	return r.Max.Y - r.Min.Y
+}
+
+func (r Rect) contains(v Vec) bool {
+	return r.Min.X <= v.X && v.X <= r.Max.X && r.Min.Y <= v.Y && v.Y <= r.Max.Y
+}
+
+func (v Vec) Sub(u Vec) Vec {
+	return Vec{v.X - u.X, v.Y - u.Y}
+}
+
+func (v Vec) Len() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Straight-line distance between two points
+func (v Vec) Dist(u Vec) float64 {
+	return v.Sub(u).Len()
+}
@@ -1,34 +1,27 @@
 package entity
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"strconv"
+	"math/rand"
+	"path"
+	"sort"
 	"sync"
+	"time"
 
-	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/text"
 	"github.com/gonum/graph"
 	"github.com/gonum/graph/simple"
-	"golang.org/x/image/colornames"
 )
 
 // Extends graph.Node with necessary properties
 type PositionedNode struct {
-	Id       int
-	Name     string
-	Selected bool // Used only for map editor
-	Weight   int  // How difficult it is to attack this vertex
-
-	// Store the name pre-rendered
-	RenderedName *text.Text
+	Id     int
+	Name   string
+	Weight int // How difficult it is to attack this vertex
 
 	People  []*Person
 	Zombies []*Zombie
 	Items   []Item
 
-	Pos pixel.Vec
+	Pos Vec
 }
 
 // Necessary to implement graph.Node
@@ -45,195 +38,161 @@ func (n *PositionedNode) ItemPresent(t Item) bool {
 	return false
 }
 
-// Pre-render the vertex labels
-func (n *PositionedNode) RenderName(atlas *text.Atlas) {
-	n.RenderedName = text.New(n.Pos, atlas)
-	n.RenderedName.Color = colornames.Black
-	// Write to a buffer so that the size can be checked for centering purposes
-	var w bytes.Buffer
-	w.WriteString(n.Name)
-	if len(n.Items) > 0 {
-		// Prevent double-prinitng of item duplicates.
-		seen := make([]bool, N_ITEMS)
-		seen[n.Items[0]] = true
-		w.WriteString(fmt.Sprintf(" (%s", n.Items[0]))
-		for _, i := range n.Items[1:] {
-			if !seen[i] {
-				w.WriteString(fmt.Sprintf(", %s", i))
-				seen[i] = true
-			}
-		}
-		w.WriteString(")")
-	}
-	s := w.String()
-	// Center text
-	n.RenderedName.Dot.X -= n.RenderedName.BoundsOf(s).W() / 2
-	fmt.Fprintln(n.RenderedName, s)
-	n.RenderedName.Dot.X -= n.RenderedName.BoundsOf(strconv.Itoa(n.Weight)).W() / 2
-	fmt.Fprintln(n.RenderedName, n.Weight)
-}
-
-// Extends simple.Undirected graph, adding display-related things
+// Extends simple.Undirected graph, adding map-related things
 type MapGraph struct {
 	*simple.UndirectedGraph
 
-	atlas      *text.Atlas
-	Bounds     pixel.Rect
+	Bounds     Rect
 	VertexSize float64
 
 	entities uint
 
-	Log chan string
+	// All randomness in the simulation comes from here, so that a seed reproduces a run
+	Rand   *rand.Rand
+	source *lockedSource
 
-	Mutex *sync.RWMutex
+	Tunables Tunables
 
-	Changed bool
-}
+	// Simulation time
+	Clock Clock
 
-func NewMapGraph(atlas *text.Atlas, bounds pixel.Rect, vertexSize float64) *MapGraph {
-	return &MapGraph{simple.NewUndirectedGraph(0, -1), atlas, bounds, vertexSize, 0, make(chan string, 100), &sync.RWMutex{}, true}
-}
-
-func (g *MapGraph) NewPositionedNode(name string, x float64, y float64, w int) *PositionedNode {
-	n := &PositionedNode{g.UndirectedGraph.NewNodeID(), name, false, w, nil, make([]*Person, 0, 5), make([]*Zombie, 0, 5), make([]Item, 0, 2), pixel.V(x, y)}
-	n.RenderName(g.atlas)
-	return n
-}
-
-// Because writing Go is marginally easier than writing JSON.
-/*func (g *MapGraph) PopulateMap() {
-	for _, v := range g.Nodes() {
-		v.People = make([]*Person, 0, 5)
-	}
-	g.entities = 0
-	rand.Seed(time.Now().UnixNano())
-	g.AddPerson(Engineer, g.GetVertexByName("Water Treatment Plant"))
+	subscribers []func(Event)
 
-	g.AddPerson(Engineer, g.GetVertexByName("Garage"))
-
-	g.AddPerson(Other, g.GetVertexByName("General Store"))
-	g.AddPerson(Other, g.GetVertexByName("General Store"))
-
-	g.AddPerson(Priest, g.GetVertexByName("Church"))
-
-	g.AddPerson(Other, g.GetVertexByName("Hardware Store"))
-	g.AddPerson(Other, g.GetVertexByName("Hardware Store"))
-
-	g.AddPerson(Police, g.GetVertexByName("Police Station"))
-	g.AddPerson(Police, g.GetVertexByName("Police Station"))
-
-	g.AddPerson(Other, g.GetVertexByName("Store 1"))
-	g.AddPerson(Other, g.GetVertexByName("Store 2"))
-	g.AddPerson(Other, g.GetVertexByName("Store 3"))
-
-	g.AddPerson(Other, g.GetVertexByName("Restaurant 1"))
-	g.AddPerson(Other, g.GetVertexByName("Restaurant 1"))
-
-	g.AddPerson(Other, g.GetVertexByName("Restaurant 2"))
-	g.AddPerson(Other, g.GetVertexByName("Restaurant 2"))
+	Mutex *sync.RWMutex
 
-	g.AddPerson(Other, g.GetVertexByName("Gas Station"))
-	g.AddPerson(Other, g.GetVertexByName("Convenience Store"))
+	Changed bool
 
-	g.AddPerson(Engineer, g.GetVertexByName("Warehouse 1"))
-	g.AddPerson(Other, g.GetVertexByName("Warehouse 1"))
-	g.AddPerson(Other, g.GetVertexByName("Warehouse 1"))
+	// Shortest paths between every pair of vertices (see pathtable.go)
+	paths *pathCache
 
-	g.AddPerson(Other, g.GetVertexByName("Warehouse 2"))
-	g.AddPerson(Other, g.GetVertexByName("Warehouse 2"))
+	// Distance from each vertex to the nearest person, during the zombies' turn, and the other way round (see field.go)
+	humans  map[int]float64
+	threats map[int]float64
+}
 
-	g.AddPerson(Other, g.GetVertexByName("Warehouse 3"))
-	g.AddPerson(Other, g.GetVertexByName("Warehouse 3"))
-	g.AddPerson(Engineer, g.GetVertexByName("Warehouse 3"))
+func NewMapGraph(bounds Rect, vertexSize float64) *MapGraph {
+	source := newSource(time.Now().UnixNano())
+	return &MapGraph{simple.NewUndirectedGraph(0, -1), bounds, vertexSize, 0, rand.New(source), source, DefaultTunables, Clock{Speed: 1}, nil, &sync.RWMutex{}, true, &pathCache{}, nil, nil}
+}
 
-	g.AddPerson(Doctor, g.GetVertexByName("Doctor's Office"))
-	g.AddPerson(Other, g.GetVertexByName("Doctor's Office"))
+/*
+** A rand.Source which is safe to share between goroutines. It counts the numbers it hands out,
+** so its state can be saved as the seed and the count, and restored by drawing that many again.
+ */
+type lockedSource struct {
+	mutex sync.Mutex
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
 
-	g.AddPerson(Soldier, g.GetVertexByName("Trailer 12"))
+func (s *lockedSource) Int63() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.draws++
+	return s.src.Int63()
+}
 
-	g.AddPerson(Firefighter, g.GetVertexByName("Fire Station"))
-	g.AddPerson(Firefighter, g.GetVertexByName("Fire Station"))
+func (s *lockedSource) Uint64() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.draws++
+	return s.src.Uint64()
+}
 
-	g.AddPerson(Soldier, g.GetVertexByName("House 3"))
+func (s *lockedSource) Seed(seed int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.src.Seed(seed)
+	s.seed, s.draws = seed, 0
+}
 
-	for i := 1; i <= 15; i++ {
-		if rand.Intn(3) == 0 {
-			continue
-		}
-		c := rand.Intn(3) + 1
-		for j := 0; j < c; j++ {
-			p := Profession(rand.Intn(13))
-			if p > Other {
-				p = Other
-			}
-			g.AddPerson(p, g.GetVertexByName(fmt.Sprintf("House %d", i)))
-		}
-	}
+// The seed last given, and how many numbers have been drawn since
+func (s *lockedSource) state() (int64, uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.seed, s.draws
+}
 
-	for i := 1; i <= 16; i++ {
-		if rand.Intn(2) == 0 {
-			continue
-		}
-		c := rand.Intn(2) + 1
-		for j := 0; j < c; j++ {
-			p := Profession(rand.Intn(20))
-			if p > Other {
-				p = Other
-			}
-			g.AddPerson(p, g.GetVertexByName(fmt.Sprintf("Trailer %d", i)))
-		}
+// Go back to a state from state
+func (s *lockedSource) restore(seed int64, draws uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.src.Seed(seed)
+	for i := uint64(0); i < draws; i++ {
+		s.src.Int63()
 	}
+	s.seed, s.draws = seed, draws
+}
 
-	for i := 1; i <= 15; i++ {
-		p := Profession(rand.Intn(20))
-		if p > Other {
-			p = Other
-		}
-		pos := g.Nodes()[rand.Intn(len(g.Nodes()))]
-		for ; len(pos.People) >= 2; pos = g.Nodes()[rand.Intn(len(g.Nodes()))] {
-		}
-		g.AddPerson(p, pos)
-	}
+func newSource(seed int64) *lockedSource {
+	return &lockedSource{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
 
-	fmt.Println(g.entities)
+// Reset the simulation RNG. The same seed and map always give the same outbreak.
+func (g *MapGraph) Seed(seed int64) {
+	g.Rand.Seed(seed)
+}
 
-	for _, v := range g.Nodes() {
-		for _, p := range v.People {
-			fmt.Printf("%s is at %s with %s.\n", p.Profession, g.Nodes()[p.Location].Name, p.Items)
-		}
-	}
-}*/
+func (g *MapGraph) NewPositionedNode(name string, x float64, y float64, w int) *PositionedNode {
+	return &PositionedNode{g.UndirectedGraph.NewNodeID(), name, w, make([]*Person, 0, 5), make([]*Zombie, 0, 5), make([]Item, 0, 2), V(x, y)}
+}
 
 // Add a new person to a vertex
-func (g *MapGraph) AddPerson(job Profession, vertex *PositionedNode) {
+func (g *MapGraph) AddPerson(job Profession, vertex *PositionedNode) *Person {
+	return g.addPerson(job, vertex, nil)
+}
+
+// As AddPerson, but carrying items in place of their profession's loadout, unless items is nil
+func (g *MapGraph) addPerson(job Profession, vertex *PositionedNode, items []Item) *Person {
+	p := NewPerson(g.entities, job, vertex.ID(), g.Rand)
+	if items != nil {
+		p.Items = append([]Item(nil), items...)
+	}
 	g.Mutex.Lock()
-	vertex.People = append(vertex.People, NewPerson(g.entities, job, vertex.ID()))
+	vertex.People = append(vertex.People, p)
 	g.entities++
+	g.Changed = true
 	g.Mutex.Unlock()
+	g.emit(Event{Kind: Spawn, Actor: p.actor(), Vertex: vertex.ID(), To: -1, Items: append([]Item(nil), p.Items...)})
+	return p
 }
 
-func (g *MapGraph) AddNewZombie(vertex *PositionedNode) {
+func (g *MapGraph) AddNewZombie(vertex *PositionedNode) *Zombie {
 	z := NewZombie(g.entities, vertex.ID())
+	z.wait = g.Rand.Intn(maxStartDelay)
 	g.Mutex.Lock()
 	vertex.Zombies = append(vertex.Zombies, z)
 	g.entities++
 	g.Changed = true
 	g.Mutex.Unlock()
-	go z.Unlive(g)
+	g.emit(Event{Kind: Spawn, Actor: z.actor(), Vertex: vertex.ID(), To: -1})
+	return z
 }
 
 func (g *MapGraph) InfectPerson(p *Person) {
+	g.infect(nil, p)
+}
 
-	z := NewZombieFromPerson(p)
+// Turn a person into a zombie. by is the zombie responsible, if any.
+func (g *MapGraph) infect(by *Zombie, p *Person) {
+	z := NewZombieFromPerson(p, g.Rand)
+	z.wait = g.Rand.Intn(maxStartDelay)
 
-	p.Kill <- "INFECTED by ZOMBIE"
+	p.dead = true
+	g.RemovePerson(p)
 
+	g.Mutex.Lock()
 	n := g.Node(p.Location)
 	n.Zombies = append(n.Zombies, z)
 	g.Changed = true
+	g.Mutex.Unlock()
 
-	go z.Unlive(g)
-
+	e := Event{Kind: Infection, Target: p.actor(), Vertex: n.ID(), To: -1, Item: z.Holding}
+	if by != nil {
+		e.Actor = by.actor()
+	}
+	g.emit(e)
 }
 
 func (g *MapGraph) RemovePerson(p *Person) {
@@ -251,14 +210,16 @@ func (g *MapGraph) RemovePerson(p *Person) {
 		return
 	}
 
-	close(p.Kill)
-	close(p.Damage)
-
 	if len(n.People) > 1 {
 		n.People = append(n.People[:i], n.People[i+1:]...)
 	} else {
 		n.People = nil
 	}
+	p.leaveSquad()
+	// Zombies yet to act this tick mustn't chase someone who isn't there any more
+	if g.humans != nil && len(n.People) == 0 {
+		g.humans = g.distanceField(hasPeople)
+	}
 	g.Changed = true
 	g.Mutex.Unlock()
 }
@@ -268,11 +229,16 @@ func (g *MapGraph) RemoveZombie(z *Zombie) {
 	n := g.Node(z.Location)
 	i := 0
 	for _, v := range n.Zombies {
-		if v.Id == z.Id {
+		if v == z {
 			break
 		}
 		i++
 	}
+	if i == len(n.Zombies) {
+		g.Mutex.Unlock()
+		return
+	}
+
 	if len(n.Zombies) > 1 {
 		n.Zombies = append(n.Zombies[:i], n.Zombies[i+1:]...)
 	} else {
@@ -281,98 +247,18 @@ func (g *MapGraph) RemoveZombie(z *Zombie) {
 	g.Changed = true
 	g.Mutex.Unlock()
 }
-func (g *MapGraph) StartEntities() {
-	for _, v := range g.Nodes() {
-		for _, p := range v.People {
-			go p.Live(g)
-		}
 
-		for _, z := range v.Zombies {
-			go z.Unlive(g)
-		}
+// Count the people and zombies currently on the map
+func (g *MapGraph) Population() (people int, zombies int) {
+	g.Mutex.RLock()
+	for _, v := range g.Nodes() {
+		people += len(v.People)
+		zombies += len(v.Zombies)
 	}
+	g.Mutex.RUnlock()
+	return
 }
 
-/*
-** Go's JSON library and type system conspire to make
-** it impossible to serialize anything unexported
-** as well as impossible to deserialize anything
-** to a field without a concrete type.
-** Hence, these monstrosities.
- */
-
-/*
-** An intermediate type to allow easier serialization
-** and deserialization of the embedded UndirectedGraph.
- */
-type intermediateUndirectedGraph struct {
-	Nodes []*PositionedNode
-	Edges []*simple.Edge
-}
-
-// Wow it's actually not ridiculous
-func (g *MapGraph) Serialize() ([]byte, error) {
-	return json.Marshal(struct {
-		G *MapGraph
-		U intermediateUndirectedGraph
-	}{g, intermediateUndirectedGraph{g.Nodes(), g.Edges()}})
-}
-
-// This is a disaster but there genuinely seems to be no real good way to do it.
-func (g *MapGraph) Deserialize(data []byte) error {
-	p := make(map[string]json.RawMessage)
-	err := json.Unmarshal(data, &p)
-	if err != nil {
-		return err
-	}
-
-	err = json.Unmarshal(p["G"], &g)
-	if err != nil {
-		return err
-	}
-
-	iug := new(intermediateUndirectedGraph)
-	serIug := make(map[string]json.RawMessage)
-	err = json.Unmarshal(p["U"], &serIug)
-	if err != nil {
-		return err
-	}
-
-	err = json.Unmarshal(serIug["Nodes"], &iug.Nodes)
-	if err != nil {
-		return err
-	}
-
-	for _, v := range iug.Nodes {
-		v.RenderName(g.atlas)
-		g.AddNode(v)
-	}
-
-	serEdges := make([]json.RawMessage, len(iug.Nodes)*2)
-	err = json.Unmarshal(serIug["Edges"], &serEdges)
-	if err != nil {
-		return err
-	}
-
-	for _, se := range serEdges {
-		em := make(map[string]float64)
-		err = json.Unmarshal(se, &em)
-		if err != nil {
-			return err
-		}
-
-		e := new(simple.Edge)
-		e.F = simple.Node(em["F"])
-		e.T = simple.Node(em["T"])
-		e.W = em["W"]
-
-		if !g.HasEdgeBetween(e.F, e.T) {
-			g.SetEdge(e)
-		}
-	}
-
-	return nil
-}
 func (g *MapGraph) GetVertexByName(name string) *PositionedNode {
 	for _, v := range g.Nodes() {
 		if v.Name == name {
@@ -382,9 +268,25 @@ func (g *MapGraph) GetVertexByName(name string) *PositionedNode {
 	return nil
 }
 
+// Returns the vertices whose names match a shell pattern, like "House *", in ID order
+func (g *MapGraph) GetVerticesMatching(pattern string) ([]*PositionedNode, error) {
+	var ret []*PositionedNode
+	for _, v := range g.Nodes() {
+		match, err := path.Match(pattern, v.Name)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			ret = append(ret, v)
+		}
+	}
+	return ret, nil
+}
+
 func (g *MapGraph) AddNode(n graph.Node) {
 	g.Mutex.Lock()
 	g.UndirectedGraph.AddNode(n)
+	g.invalidatePaths()
 	// Allows re-rendering only when the graph is actually Changed
 	g.Changed = true
 	g.Mutex.Unlock()
@@ -398,20 +300,37 @@ func (g *MapGraph) Node(id int) *PositionedNode {
 	return g.UndirectedGraph.Node(id).(*PositionedNode)
 }
 
-// Returns vertices, asserting they are all PositionedNodes
+/*
+** Returns vertices, asserting they are all PositionedNodes.
+** Sorted by ID, because the underlying graph returns them in map order,
+** which would make seeded runs unrepeatable.
+ */
 func (g *MapGraph) Nodes() []*PositionedNode {
 	nodes := g.UndirectedGraph.Nodes()
 	ret := make([]*PositionedNode, len(nodes))
 	for i, n := range nodes {
 		ret[i] = n.(*PositionedNode)
 	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Id < ret[j].Id })
+
+	return ret
+}
+
+// Returns the vertices adjacent to n, sorted by ID for the same reason as Nodes
+func (g *MapGraph) Neighbors(n *PositionedNode) []*PositionedNode {
+	nodes := g.From(n)
+	ret := make([]*PositionedNode, len(nodes))
+	for i, t := range nodes {
+		ret[i] = t.(*PositionedNode)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Id < ret[j].Id })
 
 	return ret
 }
 
 /*
 ** Returns edges, asserting that they are all concretely typed
-** Necessary for nice serialization
+** Necessary for nice serialization. Sorted by the IDs of their ends, for the same reason as Nodes.
  */
 func (g *MapGraph) Edges() []*simple.Edge {
 	edges := g.UndirectedGraph.Edges()
@@ -419,12 +338,23 @@ func (g *MapGraph) Edges() []*simple.Edge {
 	for i, n := range edges {
 		ret[i] = n.(*simple.Edge)
 	}
+	ends := func(e *simple.Edge) (int, int) {
+		if e.From().ID() < e.To().ID() {
+			return e.From().ID(), e.To().ID()
+		}
+		return e.To().ID(), e.From().ID()
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ends(ret[i])
+		c, d := ends(ret[j])
+		return a < c || (a == c && b < d)
+	})
 
 	return ret
 }
 
 func (g *MapGraph) AddEdge(from *PositionedNode, to *PositionedNode, weight float64) {
 	g.Mutex.Lock()
-	g.SetEdge(simple.Edge{simple.Node(from.ID()), simple.Node(to.ID()), weight})
+	g.SetEdge(&simple.Edge{F: simple.Node(from.ID()), T: simple.Node(to.ID()), W: weight})
 	g.Mutex.Unlock()
 }
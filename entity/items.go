@@ -0,0 +1,148 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/*
+** The item registry. Every item is described by an ItemDef, and an Item is its index in the registry.
+** The built-in items come first, in the order of their constants, and items loaded from a file
+** (see LoadItems) either replace a built-in item of the same name, or are added after them.
+ */
+
+type ItemDef struct {
+	Name         string `json:"name"`
+	Abbreviation string `json:"abbreviation"` // Shown on vertex labels
+	Damage       uint   `json:"damage"`
+
+	Consumable bool `json:"consumable"` // Used up when used
+	Stackable  bool `json:"stackable"`  // Any number take up one inventory slot
+	Wieldable  bool `json:"wieldable"`  // Zombies can pick it up and use it
+	Fixed      bool `json:"fixed"`      // Can't be picked up at all, like a water source
+}
+
+var builtinItems = []ItemDef{
+	Chainsaw:            {"CHAINSAW", "CS", 45, false, false, true, false},
+	Pistol:              {"PISTOL", "PSTL", 50, false, false, true, false},
+	Rifle:               {"RIFLE", "RFL", 60, false, false, true, false},
+	EnergyBar:           {"ENERGY BAR", "EB", 10, true, false, true, false},
+	Water:               {"WATER SOURCE", "WS", 10, false, false, false, true},
+	WaterBottle:         {"WATER BOTTLE", "WB", 10, true, false, true, false},
+	RustyPipe:           {"RUSTY PIPE", "RP", 25, false, false, true, false},
+	Hatchet:             {"HATCHET", "HTCHT", 30, false, false, true, false},
+	AerosolFlamethrower: {"IMPROVISED AEROSOL FLAMETHROWER", "IAF", 20, true, false, true, false},
+	Bandage:             {"BANDAGE", "BDG", 10, true, false, true, false},
+	Wrench:              {"WRENCH", "WRNC", 20, false, false, true, false},
+	Hacksaw:             {"HACKSAW", "HS", 15, false, false, true, false},
+	RPG:                 {"ROCKET-PROPELLED GRENADE LAUNCHER", "RPG", 100, true, false, true, false},
+	ATGM:                {"ANTI-TANK GUIDED MISSILE", "ATGM", 200, true, false, true, false},
+	HolyWater:           {"HOLY WATER", "HW", 15, true, false, true, false},
+	// Bare hands
+	Nothing: {"NOTHING", "NT", 10, false, false, true, true},
+}
+
+var items = append([]ItemDef(nil), builtinItems...)
+
+var invalidItem = ItemDef{Name: "INVALID ITEM", Abbreviation: "INVALID ITEM"}
+
+func (i Item) def() *ItemDef {
+	if int(i) >= len(items) {
+		return &invalidItem
+	}
+	return &items[i]
+}
+
+func (i Item) Damage() uint {
+	return i.def().Damage
+}
+
+func (i Item) Consumable() bool {
+	return i.def().Consumable
+}
+
+func (i Item) Stackable() bool {
+	return i.def().Stackable
+}
+
+// Whether zombies can pick it up
+func (i Item) Wieldable() bool {
+	return i.def().Wieldable && !i.def().Fixed
+}
+
+func (i Item) Fixed() bool {
+	return i.def().Fixed
+}
+
+func (i Item) StringLong() string {
+	return i.def().Name
+}
+
+func (i Item) String() string {
+	return i.def().Abbreviation
+}
+
+// Whether the item is in the registry, and is an actual item rather than Nothing
+func (i Item) Valid() bool {
+	return int(i) < len(items) && i != Nothing
+}
+
+// Every item in the registry, apart from Nothing
+func Items() []Item {
+	ret := make([]Item, 0, len(items)-1)
+	for i := range items {
+		if Item(i) != Nothing {
+			ret = append(ret, Item(i))
+		}
+	}
+	return ret
+}
+
+// Look an item up by name or abbreviation, ignoring case
+func ItemNamed(name string) (Item, bool) {
+	for i, d := range items {
+		if strings.EqualFold(d.Name, name) || strings.EqualFold(d.Abbreviation, name) {
+			return Item(i), true
+		}
+	}
+	return Nothing, false
+}
+
+// Load item definitions from a JSON array of ItemDefs, on top of the built-in items
+func LoadItems(path string) error {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var defs []ItemDef
+	err = json.Unmarshal(s, &defs)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	registry := append([]ItemDef(nil), builtinItems...)
+	for _, d := range defs {
+		if d.Name == "" || d.Abbreviation == "" {
+			return fmt.Errorf("%s: every item needs a name and an abbreviation", path)
+		}
+		d.Name = strings.ToUpper(d.Name)
+
+		replaced := false
+		for i := range registry {
+			if registry[i].Name == d.Name {
+				registry[i] = d
+				replaced = true
+			} else if strings.EqualFold(registry[i].Abbreviation, d.Abbreviation) {
+				return fmt.Errorf("%s: %s has the same abbreviation as %s", path, d.Name, registry[i].Name)
+			}
+		}
+		if !replaced {
+			registry = append(registry, d)
+		}
+	}
+
+	items = registry
+	return nil
+}
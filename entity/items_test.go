@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Returns a function which puts the registries back as they were, for tests which load their own
+func keepRegistries() func() {
+	saved, savedProfessions, savedCommon := items, professions, commonLoadout
+	return func() {
+		items, professions, commonLoadout = saved, savedProfessions, savedCommon
+	}
+}
+
+// Load data through a temporary file
+func loadTemp(t *testing.T, load func(string) error, data string) error {
+	f, err := ioutil.TempFile("", "zombies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(data)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return load(f.Name())
+}
+
+func TestLoadItems(t *testing.T) {
+	defer keepRegistries()()
+	if err := LoadItems("../items.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if Pistol.StringLong() != "PISTOL" || Pistol.Damage() != 50 || !Water.Fixed() {
+		t.Errorf("the built-in items changed")
+	}
+	crowbar, ok := ItemNamed("crowbar")
+	if !ok || crowbar != Nothing+1 || crowbar.Damage() != 30 || crowbar.String() != "CRWBR" {
+		t.Errorf("got %d for the crowbar, want the first item after the built-in ones", crowbar)
+	}
+	molotov, ok := ItemNamed("MLTV")
+	if !ok || molotov != crowbar+1 || !molotov.Consumable() || molotov.Wieldable() {
+		t.Errorf("got %d for the molotov cocktail, want a consumable that zombies can't wield", molotov)
+	}
+	if n := len(Items()); n != int(molotov) {
+		t.Errorf("got %d items, want all but Nothing", n)
+	}
+}
+
+func TestLoadItemsErrors(t *testing.T) {
+	defer keepRegistries()()
+	for _, data := range []string{
+		`[{"name": "CROWBAR"}]`,
+		`[{"name": "CROWBAR", "abbreviation": "pstl"}]`,
+		`{"name": "CROWBAR", "abbreviation": "CRWBR"}`,
+	} {
+		if err := loadTemp(t, LoadItems, data); err == nil {
+			t.Errorf("loaded %s", data)
+		}
+		if _, ok := ItemNamed("CROWBAR"); ok {
+			t.Errorf("a bad file still added items")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Population counts at the end of one tick, in the spirit of an SIR model
+type Sample struct {
+	Tick          uint64
+	Healthy       int // People still alive and uninfected
+	Zombies       int
+	Dead          int // People who have died, not counting those who turned
+	Destroyed     int // Zombies which have been killed or starved
+	NewInfections int // People turned during this tick
+}
+
+/*
+** Collects a Sample for every tick, from the simulation's events. Everyone is counted again from the graph
+** after each event, so changes which don't come with events, like editing the map or restoring a snapshot,
+** are caught. Going back in time, as when seeking backwards through a replay, forgets the samples since.
+ */
+type Metrics struct {
+	Samples []Sample
+
+	g           *MapGraph
+	current     Sample
+	unsubscribe func()
+}
+
+// Start collecting samples, from the graph's current tick
+func NewMetrics(g *MapGraph) *Metrics {
+	people, zombies := g.Population()
+	m := &Metrics{g: g, current: Sample{Tick: g.Clock.Ticks, Healthy: people, Zombies: zombies}}
+	m.unsubscribe = g.Subscribe(m.record)
+	return m
+}
+
+func (m *Metrics) record(e Event) {
+	m.advance(e.Time)
+	m.current.Healthy, m.current.Zombies = m.g.Population()
+
+	switch e.Kind {
+	case Infection:
+		m.current.NewInfections++
+	case Death:
+		if e.Actor.Zombie {
+			m.current.Destroyed++
+		} else {
+			m.current.Dead++
+		}
+	}
+}
+
+// Finish the samples for every tick before this one. Ticks without events still get a sample.
+func (m *Metrics) advance(tick uint64) {
+	if tick < m.current.Tick {
+		i := sort.Search(len(m.Samples), func(i int) bool { return m.Samples[i].Tick >= tick })
+		m.Samples = m.Samples[:i]
+		m.current = Sample{Tick: tick}
+		if i > 0 {
+			m.current.Dead, m.current.Destroyed = m.Samples[i-1].Dead, m.Samples[i-1].Destroyed
+		}
+	}
+	for m.current.Tick < tick {
+		m.Samples = append(m.Samples, m.current)
+		m.current.Tick++
+		m.current.NewInfections = 0
+	}
+}
+
+// Stop collecting, finishing the sample for the current tick
+func (m *Metrics) Stop() {
+	if m.unsubscribe == nil {
+		return
+	}
+	m.unsubscribe()
+	m.unsubscribe = nil
+	m.advance(m.g.Clock.Ticks)
+	m.current.Healthy, m.current.Zombies = m.g.Population()
+	m.Samples = append(m.Samples, m.current)
+}
+
+// Write one row per tick, with a header
+func (m *Metrics) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"tick", "seconds", "healthy", "zombies", "dead", "destroyed", "new_infections"})
+	for _, s := range m.Samples {
+		out.Write([]string{
+			strconv.FormatUint(s.Tick, 10),
+			strconv.FormatFloat((time.Duration(s.Tick) * TickDuration).Seconds(), 'f', -1, 64),
+			strconv.Itoa(s.Healthy),
+			strconv.Itoa(s.Zombies),
+			strconv.Itoa(s.Dead),
+			strconv.Itoa(s.Destroyed),
+			strconv.Itoa(s.NewInfections),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
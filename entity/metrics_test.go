@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"testing"
+)
+
+// Step n ticks, returning the population at the end of each
+func populations(g *MapGraph, n int) [][2]int {
+	var ret [][2]int
+	for i := 0; i < n; i++ {
+		g.Step()
+		people, zombies := g.Population()
+		ret = append(ret, [2]int{people, zombies})
+	}
+	return ret
+}
+
+func checkSamples(t *testing.T, m *Metrics, from uint64, want [][2]int) {
+	for i, w := range want {
+		tick := from + uint64(i)
+		if tick >= uint64(len(m.Samples)) {
+			t.Fatalf("no sample for tick %d", tick)
+		}
+		if s := m.Samples[tick]; s.Tick != tick || s.Healthy != w[0] || s.Zombies != w[1] {
+			t.Errorf("sample %+v, want %d people and %d zombies at tick %d", s, w[0], w[1], tick)
+		}
+	}
+}
+
+// Restoring an earlier snapshot takes the samples back with it
+func TestMetricsFollowRestore(t *testing.T) {
+	g := snapshotWorld(t)
+	m := NewMetrics(g)
+	record(g, 20)
+	data, err := g.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(g, 60)
+	if err = g.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	want := populations(g, 10)
+	m.Stop()
+
+	if len(m.Samples) != 31 {
+		t.Errorf("got %d samples, want one for each of ticks 0 to 30", len(m.Samples))
+	}
+	checkSamples(t, m, 21, want)
+}
+
+// Changes from the editor, which come without events, are counted
+func TestMetricsCountEdits(t *testing.T) {
+	g := snapshotWorld(t)
+	m := NewMetrics(g)
+	g.Step()
+	rules := PopulationRules{[]PopulationRule{{At: "*", Scatter: 5}}}
+	if err := rules.Populate(g); err != nil {
+		t.Fatal(err)
+	}
+	people, zombies := g.Population()
+	want := append([][2]int{{people, zombies}}, populations(g, 10)...)
+	m.Stop()
+
+	checkSamples(t, m, 1, want)
+}
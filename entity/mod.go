@@ -6,10 +6,10 @@ import (
 
 // Data structures representing people, zombies, and items
 
+// Items are numbered by their place in the item registry (see items.go).
+// These are the built-in ones, which the simulation knows how to use.
 type Item uint
 
-const N_ITEMS int = 15
-
 const (
 	Chainsaw Item = iota
 	Pistol
@@ -29,123 +29,7 @@ const (
 	Nothing
 )
 
-func (i Item) Damage() uint {
-	switch i {
-	case Chainsaw:
-		return 45
-	case Pistol:
-		return 50
-	case Rifle:
-		return 60
-	case RustyPipe:
-		return 25
-	case Hatchet:
-		return 30
-	case AerosolFlamethrower:
-		return 20
-	case Wrench:
-		return 20
-	case Hacksaw:
-		return 15
-	case RPG:
-		return 100
-	case ATGM:
-		return 200
-	case HolyWater:
-		return 15
-	default:
-		return 10
-	}
-}
-
-func (i Item) Consumable() bool {
-	if i == EnergyBar || i == WaterBottle || i == AerosolFlamethrower || i == Bandage || i == HolyWater || i == ATGM || i == RPG {
-		return true
-	} else {
-		return false
-	}
-}
-
-func (i Item) StringLong() string {
-	switch i {
-	case Chainsaw:
-		return "CHAINSAW"
-	case Pistol:
-		return "PISTOL"
-	case Rifle:
-		return "RIFLE"
-	case EnergyBar:
-		return "ENERGY BAR"
-	case Water:
-		return "WATER SOURCE"
-	case WaterBottle:
-		return "WATER BOTTLE"
-	case RustyPipe:
-		return "RUSTY PIPE"
-	case Hatchet:
-		return "HATCHET"
-	case AerosolFlamethrower:
-		return "IMPROVISED AEROSOL FLAMETHROWER"
-	case Bandage:
-		return "BANDAGE"
-	case Wrench:
-		return "WRENCH"
-	case Hacksaw:
-		return "HACKSAW"
-	case RPG:
-		return "ROCKET-PROPELLED GRENADE LAUNCHER"
-	case ATGM:
-		return "ANTI-TANK GUIDED MISSILE"
-	case HolyWater:
-		return "HOLY WATER"
-	case Nothing:
-		return "NOTHING"
-	default:
-		return "INVALID ITEM"
-	}
-	return "INVALID ITEM"
-}
-
-func (i Item) String() string {
-	switch i {
-	case Chainsaw:
-		return "CS"
-	case Pistol:
-		return "PSTL"
-	case Rifle:
-		return "RFL"
-	case EnergyBar:
-		return "EB"
-	case Water:
-		return "WS"
-	case WaterBottle:
-		return "WB"
-	case RustyPipe:
-		return "RP"
-	case Hatchet:
-		return "HTCHT"
-	case AerosolFlamethrower:
-		return "IAF"
-	case Bandage:
-		return "BDG"
-	case Wrench:
-		return "WRNC"
-	case Hacksaw:
-		return "HS"
-	case RPG:
-		return "RPG"
-	case ATGM:
-		return "ATGM"
-	case HolyWater:
-		return "HW"
-	case Nothing:
-		return "NT"
-	default:
-		return "INVALID ITEM"
-	}
-	return "INVALID ITEM"
-}
-
+// Professions are numbered by their place in the profession registry (see professions.go), like items
 type Profession uint
 
 const (
@@ -158,28 +42,6 @@ const (
 	Other
 )
 
-func (p Profession) String() string {
-	switch p {
-	case Police:
-		return "POLICE OFFICER"
-	case Firefighter:
-		return "FIREFIGHTER"
-	case Soldier:
-		return "SOLDIER"
-	case Doctor:
-		return "DOCTOR"
-	case Engineer:
-		return "ENGINEER"
-	case Other:
-		return "OTHER"
-	case Priest:
-		return "PRIEST"
-	default:
-		return "INVALID PROFESSION"
-	}
-	return "INVALID PROFESSION"
-}
-
 type Person struct {
 	Id         uint
 	Health     int
@@ -188,54 +50,71 @@ type Person struct {
 	Items      []Item
 	Profession Profession
 	Location   int
-	Damage     chan DamageMessage
-	Kill       chan string
+
+	// Damage taken since the person last acted
+	damage []DamageMessage
+	dead   bool
+	// Ticks to wait before acting again, and where the person will be when they have passed
+	wait int
+	dest *PositionedNode
+	// The tick they last set off on, and the squad they're in, if any
+	departed uint64
+	squad    *Squad
+	// Where they're heading for something they need, if anywhere
+	goal *PositionedNode
 }
 
 func (p *Person) AddItem(items ...Item) {
 	p.Items = append(p.Items, items...)
 }
 
-func NewPerson(id uint, job Profession, pos int) *Person {
-	ret := &Person{id, 100, 0, 0, make([]Item, 0, 2), job, pos, make(chan DamageMessage, 20), make(chan string, 20)}
-	switch job {
-	case Police:
-		ret.AddItem(Pistol)
-	case Firefighter:
-		if rand.Intn(5) == 2 {
-			ret.AddItem(Chainsaw)
-		} else {
-			ret.AddItem(Hatchet)
+// Inventory slots in use. Stackable items of the same kind share one.
+func (p *Person) slots() int {
+	n := 0
+	for i, item := range p.Items {
+		if !item.Stackable() {
+			n++
+			continue
 		}
-	case Soldier:
-		ret.AddItem(Rifle)
-		if rand.Intn(5) == 0 {
-			if rand.Intn(3) == 0 {
-				ret.AddItem(ATGM)
-			} else {
-				ret.AddItem(RPG)
+		first := true
+		for _, other := range p.Items[:i] {
+			if other == item {
+				first = false
+				break
 			}
 		}
-	case Doctor:
-		ret.AddItem(Bandage, Bandage, Bandage, Bandage, Hacksaw)
-	case Engineer:
-		ret.AddItem(Hatchet, Wrench)
-	case Priest:
-		ret.AddItem(HolyWater)
+		if first {
+			n++
+		}
 	}
+	return n
+}
 
-	if rand.Intn(3) == 1 {
-		ret.AddItem(EnergyBar)
+func (p *Person) holdingStackable() bool {
+	for _, i := range p.Items {
+		if i.Stackable() {
+			return true
+		}
 	}
+	return false
+}
 
-	if rand.Intn(2) == 0 {
-		ret.AddItem(WaterBottle)
-	}
+// Whether there's room to pick an item up
+func (p *Person) canCarry(item Item, cap int) bool {
+	return p.slots() < cap || (item.Stackable() && p.Holding(item))
+}
 
-	if rand.Intn(10) == 1 {
-		ret.AddItem(RustyPipe)
-	}
-	return ret
+// Whether they have anything better to fight with than their bare hands
+func (p *Person) armed() bool {
+	return p.BestWeapon().Damage() > Nothing.Damage()
+}
+
+func (p *Person) thirsty() bool {
+	return p.Thirst >= 100
+}
+
+func (p *Person) hungry() bool {
+	return p.Hunger >= 100
 }
 
 func (p *Person) Holding(t Item) bool {
@@ -280,26 +159,30 @@ type Zombie struct {
 	Hunger   int
 	Holding  Item
 	Location int
-	Damage   chan DamageMessage
-	Kill     chan string
+
+	// As for Person
+	damage []DamageMessage
+	dead   bool
+	wait   int
+	dest   *PositionedNode
 }
 
 type DamageMessage struct {
 	Value    uint
-	Attacker string
+	Attacker Actor
 	Item     Item
 }
 
-func NewZombieFromPerson(victim *Person) *Zombie {
+func NewZombieFromPerson(victim *Person, rng *rand.Rand) *Zombie {
 	var holding Item
 	if len(victim.Items) > 0 {
-		holding = victim.Items[rand.Intn(len(victim.Items))]
+		holding = victim.Items[rng.Intn(len(victim.Items))]
 	} else {
 		holding = Nothing
 	}
-	return &Zombie{victim.Id, 100, 0, holding, victim.Location, make(chan DamageMessage, 100), make(chan string, 20)}
+	return &Zombie{Id: victim.Id, Health: 100, Holding: holding, Location: victim.Location}
 }
 
 func NewZombie(id uint, location int) *Zombie {
-	return &Zombie{id, 100, 0, Nothing, location, make(chan DamageMessage, 100), make(chan string, 20)}
+	return &Zombie{Id: id, Health: 100, Holding: Nothing, Location: location}
 }
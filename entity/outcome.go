@@ -0,0 +1,42 @@
+package entity
+
+// What happened over the course of an outbreak
+type Outcome struct {
+	Survivors   int
+	Zombies     int    // Zombies left at the end
+	Ticks       uint64 // When either side was wiped out
+	PeakZombies int
+
+	// How people died. Those turned by zombies are counted separately, as they don't die as such.
+	Deaths   map[Cause]int
+	Infected int
+}
+
+// Run the simulation until either side has been wiped out, keeping track of what happens along the way
+func (g *MapGraph) RunOutbreak() Outcome {
+	o := Outcome{Deaths: make(map[Cause]int)}
+	unsubscribe := g.Subscribe(func(e Event) {
+		switch {
+		case e.Kind == Death && !e.Actor.Zombie:
+			o.Deaths[e.Cause]++
+		case e.Kind == Infection:
+			o.Infected++
+		}
+	})
+	defer unsubscribe()
+
+	for {
+		people, zombies := g.Population()
+		if zombies > o.PeakZombies {
+			o.PeakZombies = zombies
+		}
+		if people == 0 || zombies == 0 {
+			o.Survivors, o.Zombies = people, zombies
+			break
+		}
+		g.Step()
+	}
+	o.Ticks = g.Clock.Ticks
+
+	return o
+}
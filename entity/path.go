@@ -0,0 +1,238 @@
+package entity
+
+import (
+	"container/heap"
+	"math"
+)
+
+/*
+** Shortest paths over the map, by edge weight. Dijkstra's algorithm finds the nearest vertex satisfying some goal,
+** and A* finds the path to a known vertex, using the straight-line distance between positions as its heuristic.
+** Ties are broken by vertex ID, so a seeded run always takes the same paths.
+**
+** The exported functions take the read lock themselves. Callers already holding it use the unexported ones.
+ */
+
+// A path from its first vertex to its last, and the total weight of the edges along it
+type Path struct {
+	Vertices []*PositionedNode
+	Cost     float64
+}
+
+// The vertex after the start, or nil if the path doesn't go anywhere
+func (p *Path) FirstStep() *PositionedNode {
+	if p == nil || len(p.Vertices) < 2 {
+		return nil
+	}
+	return p.Vertices[1]
+}
+
+type pathItem struct {
+	v        *PositionedNode
+	priority float64
+}
+
+// A min-heap of vertices to visit
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int {
+	return len(q)
+}
+
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].priority == q[j].priority {
+		return q[i].v.Id < q[j].v.Id
+	}
+	return q[i].priority < q[j].priority
+}
+
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *pathQueue) Push(x interface{}) {
+	*q = append(*q, x.(pathItem))
+}
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Walk back from the end of a search to where it started
+func tracePath(g *MapGraph, previous map[int]int, from *PositionedNode, to *PositionedNode, cost float64) *Path {
+	vertices := []*PositionedNode{to}
+	for v := to.Id; v != from.Id; {
+		v = previous[v]
+		vertices = append(vertices, g.Node(v))
+	}
+	for i, j := 0, len(vertices)-1; i < j; i, j = i+1, j-1 {
+		vertices[i], vertices[j] = vertices[j], vertices[i]
+	}
+	return &Path{vertices, cost}
+}
+
+// Shortest path from from to the nearest vertex satisfying goal, or nil if none can be reached
+func (g *MapGraph) NearestPath(from *PositionedNode, goal func(*PositionedNode) bool) *Path {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return g.nearestPath(from, goal)
+}
+
+func (g *MapGraph) nearestPath(from *PositionedNode, goal func(*PositionedNode) bool) *Path {
+	return g.nearestPathAvoiding(from, goal, nil)
+}
+
+// Like NearestPath, but never going through or to a vertex for which avoid is true
+func (g *MapGraph) NearestPathAvoiding(from *PositionedNode, goal func(*PositionedNode) bool, avoid func(*PositionedNode) bool) *Path {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return g.nearestPathAvoiding(from, goal, avoid)
+}
+
+func (g *MapGraph) nearestPathAvoiding(from *PositionedNode, goal func(*PositionedNode) bool, avoid func(*PositionedNode) bool) *Path {
+	distance := map[int]float64{from.Id: 0}
+	previous := make(map[int]int)
+	done := make(map[int]bool)
+	q := &pathQueue{{from, 0}}
+
+	for q.Len() > 0 {
+		item := heap.Pop(q).(pathItem)
+		v := item.v
+		if done[v.Id] {
+			continue
+		}
+		done[v.Id] = true
+		if goal(v) {
+			return tracePath(g, previous, from, v, distance[v.Id])
+		}
+
+		for _, t := range g.Neighbors(v) {
+			if avoid != nil && avoid(t) {
+				continue
+			}
+			d := distance[v.Id] + g.Edge(v, t).Weight()
+			if old, ok := distance[t.Id]; !done[t.Id] && (!ok || d < old) {
+				distance[t.Id] = d
+				previous[t.Id] = v.Id
+				heap.Push(q, pathItem{t, d})
+			}
+		}
+	}
+
+	return nil
+}
+
+// Shortest distance from from to every vertex it can reach
+func (g *MapGraph) Distances(from *PositionedNode) map[int]float64 {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return g.distances(from)
+}
+
+func (g *MapGraph) distances(from *PositionedNode) map[int]float64 {
+	distance := map[int]float64{from.Id: 0}
+	done := make(map[int]bool)
+	q := &pathQueue{{from, 0}}
+
+	for q.Len() > 0 {
+		v := heap.Pop(q).(pathItem).v
+		if done[v.Id] {
+			continue
+		}
+		done[v.Id] = true
+		for _, t := range g.Neighbors(v) {
+			d := distance[v.Id] + g.Edge(v, t).Weight()
+			if old, ok := distance[t.Id]; !done[t.Id] && (!ok || d < old) {
+				distance[t.Id] = d
+				heap.Push(q, pathItem{t, d})
+			}
+		}
+	}
+
+	return distance
+}
+
+// Shortest path between two vertices, or nil if there isn't one
+func (g *MapGraph) ShortestPath(from *PositionedNode, to *PositionedNode) *Path {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return g.shortestPath(from, to)
+}
+
+/*
+** Edge weights are times rather than distances, so the heuristic scales straight-line distance by the lowest
+** weight per unit of distance of any edge. That way it never overestimates, and A* still finds the shortest path.
+** It's kept with the path table, and worked out again whenever that's thrown away.
+ */
+func (g *MapGraph) heuristicScale() float64 {
+	g.paths.mutex.Lock()
+	defer g.paths.mutex.Unlock()
+	if g.paths.scaled {
+		return g.paths.scale
+	}
+
+	scale := math.Inf(1)
+	// The lowest doesn't depend on the order, so there's no need to sort them
+	for _, e := range g.UndirectedGraph.Edges() {
+		length := g.Node(e.From().ID()).Pos.Dist(g.Node(e.To().ID()).Pos)
+		if length > 0 {
+			scale = math.Min(scale, e.Weight()/length)
+		}
+	}
+	if math.IsInf(scale, 1) || scale < 0 {
+		scale = 0
+	}
+	g.paths.scale, g.paths.scaled = scale, true
+	return scale
+}
+
+func (g *MapGraph) shortestPath(from *PositionedNode, to *PositionedNode) *Path {
+	return g.shortestPathAvoiding(from, to, nil)
+}
+
+// Like ShortestPath, but never going through or to a vertex for which avoid is true
+func (g *MapGraph) ShortestPathAvoiding(from *PositionedNode, to *PositionedNode, avoid func(*PositionedNode) bool) *Path {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return g.shortestPathAvoiding(from, to, avoid)
+}
+
+func (g *MapGraph) shortestPathAvoiding(from *PositionedNode, to *PositionedNode, avoid func(*PositionedNode) bool) *Path {
+	scale := g.heuristicScale()
+	h := func(v *PositionedNode) float64 {
+		return v.Pos.Dist(to.Pos) * scale
+	}
+
+	distance := map[int]float64{from.Id: 0}
+	previous := make(map[int]int)
+	done := make(map[int]bool)
+	q := &pathQueue{{from, h(from)}}
+
+	for q.Len() > 0 {
+		v := heap.Pop(q).(pathItem).v
+		if done[v.Id] {
+			continue
+		}
+		done[v.Id] = true
+		if v.Id == to.Id {
+			return tracePath(g, previous, from, v, distance[v.Id])
+		}
+
+		for _, t := range g.Neighbors(v) {
+			if avoid != nil && avoid(t) {
+				continue
+			}
+			d := distance[v.Id] + g.Edge(v, t).Weight()
+			if old, ok := distance[t.Id]; !done[t.Id] && (!ok || d < old) {
+				distance[t.Id] = d
+				previous[t.Id] = v.Id
+				heap.Push(q, pathItem{t, d + h(t)})
+			}
+		}
+	}
+
+	return nil
+}
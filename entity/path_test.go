@@ -0,0 +1,433 @@
+package entity
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type testEdge struct {
+	from, to int
+	weight   float64
+}
+
+// A graph with vertices named by their IDs, at the given positions
+func testGraph(pos []Vec, edges []testEdge) *MapGraph {
+	g := NewMapGraph(R(0, 0, 1000, 1000), 10)
+	for i, p := range pos {
+		g.AddNode(&PositionedNode{Id: i, Name: string(rune('A' + i)), Pos: p})
+	}
+	for _, e := range edges {
+		g.AddEdge(g.Node(e.from), g.Node(e.to), e.weight)
+	}
+	return g
+}
+
+func pathIDs(p *Path) []int {
+	ids := make([]int, len(p.Vertices))
+	for i, v := range p.Vertices {
+		ids[i] = v.Id
+	}
+	return ids
+}
+
+func sameIDs(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The usual example graph, from the Wikipedia article on Dijkstra's algorithm (shifted to start at 0)
+func wikipediaGraph() *MapGraph {
+	return testGraph(
+		[]Vec{V(0, 0), V(100, 100), V(100, 0), V(200, 50), V(200, -50), V(100, -100)},
+		[]testEdge{{0, 1, 7}, {0, 2, 9}, {0, 5, 14}, {1, 2, 10}, {1, 3, 15}, {2, 3, 11}, {2, 5, 2}, {3, 4, 6}, {4, 5, 9}},
+	)
+}
+
+func TestDistances(t *testing.T) {
+	g := wikipediaGraph()
+	want := map[int]float64{0: 0, 1: 7, 2: 9, 3: 20, 4: 20, 5: 11}
+	got := g.Distances(g.Node(0))
+	if len(got) != len(want) {
+		t.Fatalf("got distances to %d vertices, want %d", len(got), len(want))
+	}
+	for id, d := range want {
+		if got[id] != d {
+			t.Errorf("distance to %d is %g, want %g", id, got[id], d)
+		}
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	g := wikipediaGraph()
+	for _, c := range []struct {
+		from, to int
+		path     []int
+		cost     float64
+	}{
+		{0, 4, []int{0, 2, 5, 4}, 20},
+		{0, 3, []int{0, 2, 3}, 20},
+		{1, 5, []int{1, 2, 5}, 12},
+		{3, 3, []int{3}, 0},
+	} {
+		p := g.ShortestPath(g.Node(c.from), g.Node(c.to))
+		if p == nil {
+			t.Errorf("no path from %d to %d", c.from, c.to)
+			continue
+		}
+		if !sameIDs(pathIDs(p), c.path) || p.Cost != c.cost {
+			t.Errorf("path from %d to %d is %v costing %g, want %v costing %g", c.from, c.to, pathIDs(p), p.Cost, c.path, c.cost)
+		}
+	}
+}
+
+func TestShortestPathUnreachable(t *testing.T) {
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(20, 0)}, []testEdge{{0, 1, 1}})
+	if p := g.ShortestPath(g.Node(0), g.Node(2)); p != nil {
+		t.Errorf("found path %v to a disconnected vertex", pathIDs(p))
+	}
+	if p := g.NearestPath(g.Node(0), func(v *PositionedNode) bool { return v.Id == 2 }); p != nil {
+		t.Errorf("found path %v to a disconnected vertex", pathIDs(p))
+	}
+}
+
+// The heuristic must not lead A* down a path which is straighter but slower
+func TestShortestPathAvoidsSlowShortcut(t *testing.T) {
+	g := testGraph(
+		[]Vec{V(0, 0), V(100, 0), V(50, 10), V(50, 200)},
+		[]testEdge{{0, 2, 50}, {2, 1, 50}, {0, 3, 10}, {3, 1, 10}},
+	)
+	p := g.ShortestPath(g.Node(0), g.Node(1))
+	if !sameIDs(pathIDs(p), []int{0, 3, 1}) || p.Cost != 20 {
+		t.Errorf("path is %v costing %g, want [0 3 1] costing 20", pathIDs(p), p.Cost)
+	}
+}
+
+// The heuristic is worked out again when the edges change
+func TestHeuristicScale(t *testing.T) {
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(0, 10)}, []testEdge{{0, 1, 10}})
+	if s := g.heuristicScale(); s != 1 {
+		t.Errorf("scale is %g, want 1", s)
+	}
+	g.AddEdge(g.Node(0), g.Node(2), 2)
+	if s := g.heuristicScale(); s != 0.2 {
+		t.Errorf("scale after adding an edge is %g, want 0.2", s)
+	}
+	g.RemoveEdge(g.Edge(g.Node(0), g.Node(2)))
+	if s := g.heuristicScale(); s != 1 {
+		t.Errorf("scale after removing the edge is %g, want 1", s)
+	}
+}
+
+func TestShortestPathAvoiding(t *testing.T) {
+	g := wikipediaGraph()
+	p := g.ShortestPathAvoiding(g.Node(0), g.Node(4), func(v *PositionedNode) bool { return v.Id == 2 })
+	if !sameIDs(pathIDs(p), []int{0, 5, 4}) || p.Cost != 23 {
+		t.Errorf("path is %v costing %g, want [0 5 4] costing 23", pathIDs(p), p.Cost)
+	}
+	if p := g.ShortestPathAvoiding(g.Node(0), g.Node(4), func(v *PositionedNode) bool { return v.Id == 4 }); p != nil {
+		t.Errorf("found path %v to a vertex to avoid", pathIDs(p))
+	}
+}
+
+// A* and Dijkstra's algorithm agree on every route through a generated town
+func TestShortestPathMatchesDistances(t *testing.T) {
+	g, err := GenerateMap(GenOptions{Vertices: 80}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Vary the weights so that they aren't all the same
+	rng := rand.New(rand.NewSource(1))
+	for _, e := range g.Edges() {
+		e.W = float64(1 + rng.Intn(5))
+		g.SetEdge(e)
+	}
+
+	nodes := g.Nodes()
+	for i := 0; i < 50; i++ {
+		from, to := nodes[rng.Intn(len(nodes))], nodes[rng.Intn(len(nodes))]
+		want := g.Distances(from)[to.Id]
+		p := g.ShortestPath(from, to)
+		if p == nil {
+			t.Fatalf("no path from %s to %s in a connected map", from.Name, to.Name)
+		}
+		if math.Abs(p.Cost-want) > 1e-9 {
+			t.Errorf("A* from %s to %s costs %g, Dijkstra %g", from.Name, to.Name, p.Cost, want)
+		}
+		sum := 0.0
+		for j := 1; j < len(p.Vertices); j++ {
+			sum += g.Edge(p.Vertices[j-1], p.Vertices[j]).Weight()
+		}
+		if sum != p.Cost {
+			t.Errorf("path from %s to %s has edges adding up to %g, but says it costs %g", from.Name, to.Name, sum, p.Cost)
+		}
+	}
+}
+
+func TestNearestPath(t *testing.T) {
+	g := wikipediaGraph()
+	goal := func(v *PositionedNode) bool { return v.Id == 3 || v.Id == 5 }
+	p := g.NearestPath(g.Node(0), goal)
+	if !sameIDs(pathIDs(p), []int{0, 2, 5}) || p.Cost != 11 {
+		t.Errorf("path is %v costing %g, want [0 2 5] costing 11", pathIDs(p), p.Cost)
+	}
+	if s := p.FirstStep(); s == nil || s.Id != 2 {
+		t.Errorf("first step is %v, want vertex 2", s)
+	}
+}
+
+// Goals the same distance away are broken by ID, so seeded runs repeat
+func TestNearestPathTies(t *testing.T) {
+	g := testGraph([]Vec{V(0, 0), V(10, 0), V(-10, 0)}, []testEdge{{0, 2, 1}, {0, 1, 1}})
+	for i := 0; i < 10; i++ {
+		p := g.NearestPath(g.Node(0), func(v *PositionedNode) bool { return v.Id != 0 })
+		if p.FirstStep().Id != 1 {
+			t.Fatalf("went to %d, want 1", p.FirstStep().Id)
+		}
+	}
+}
+
+// Zombies head for the nearest person, not whoever happens to be considered last
+func TestZombiePursuesNearestPerson(t *testing.T) {
+	g := testGraph(
+		[]Vec{V(0, 0), V(10, 0), V(20, 0), V(-10, 0), V(-20, 0), V(-30, 0)},
+		[]testEdge{{0, 1, 1}, {1, 2, 1}, {0, 3, 1}, {3, 4, 1}, {4, 5, 1}},
+	)
+	g.AddPerson(Other, g.Node(2))
+	g.AddPerson(Other, g.Node(5))
+	z := g.AddNewZombie(g.Node(0))
+	if s := z.nearestPersonTraverseFirstStep(g); s == nil || s.Id != 1 {
+		t.Errorf("zombie steps to %v, want vertex 1", s)
+	}
+
+	z = g.AddNewZombie(g.Node(4))
+	if s := z.nearestPersonTraverseFirstStep(g); s == nil || s.Id != 5 {
+		t.Errorf("zombie steps to %v, want vertex 5", s)
+	}
+}
+
+func TestPathTable(t *testing.T) {
+	g := wikipediaGraph()
+	for _, from := range g.Nodes() {
+		want := g.Distances(from)
+		for _, to := range g.Nodes() {
+			d, ok := g.Distance(from, to)
+			if !ok || d != want[to.Id] {
+				t.Errorf("table distance from %d to %d is %g, want %g", from.Id, to.Id, d, want[to.Id])
+			}
+			if got, want := g.NextHop(from, to), g.ShortestPath(from, to).FirstStep(); got != want {
+				t.Errorf("table next hop from %d to %d is %v, want %v", from.Id, to.Id, got, want)
+			}
+		}
+	}
+}
+
+// A route looked up in the table is only good if it's clear all the way
+func TestClearStep(t *testing.T) {
+	g := wikipediaGraph()
+	none := func(*PositionedNode) bool { return false }
+	if s, ok := g.clearStep(g.Node(0), g.Node(4), none); !ok || s != g.NextHop(g.Node(0), g.Node(4)) {
+		t.Errorf("steps to %v, want the next hop %v", s, g.NextHop(g.Node(0), g.Node(4)))
+	}
+	// The shortest path is 0 2 5 4
+	for id, clear := range map[int]bool{2: false, 5: false, 4: false, 3: true, 1: true} {
+		avoid := func(v *PositionedNode) bool { return v.Id == id }
+		if _, ok := g.clearStep(g.Node(0), g.Node(4), avoid); ok != clear {
+			t.Errorf("avoiding %d, clear is %v, want %v", id, ok, clear)
+		}
+	}
+	if s, ok := g.clearStep(g.Node(3), g.Node(3), none); !ok || s != nil {
+		t.Errorf("steps to %v from where it's going", s)
+	}
+}
+
+// Editing the map throws the table away
+func TestPathTableInvalidation(t *testing.T) {
+	g := wikipediaGraph()
+	if d, _ := g.Distance(g.Node(0), g.Node(4)); d != 20 {
+		t.Fatalf("distance is %g, want 20", d)
+	}
+
+	g.AddEdge(g.Node(0), g.Node(4), 3)
+	if d, _ := g.Distance(g.Node(0), g.Node(4)); d != 3 {
+		t.Errorf("distance after adding an edge is %g, want 3", d)
+	}
+
+	g.AddEdge(g.Node(0), g.Node(4), 30)
+	if d, _ := g.Distance(g.Node(0), g.Node(4)); d != 20 {
+		t.Errorf("distance after reweighting the edge is %g, want 20", d)
+	}
+
+	g.RemoveEdge(g.Edge(g.Node(3), g.Node(4)))
+	g.RemoveEdge(g.Edge(g.Node(5), g.Node(4)))
+	if d, _ := g.Distance(g.Node(0), g.Node(4)); d != 30 {
+		t.Errorf("distance after removing edges is %g, want 30", d)
+	}
+
+	g.RemoveNode(g.Node(0))
+	if _, ok := g.Distance(g.Node(1), g.Node(4)); ok {
+		t.Errorf("vertex 4 is still reachable after removing the only vertex joining it to the rest")
+	}
+
+	g.AddNode(&PositionedNode{Id: 6, Name: "G"})
+	if _, ok := g.Distance(g.Node(1), g.Node(6)); ok {
+		t.Errorf("a new vertex with no edges is reachable")
+	}
+}
+
+// The table finds the same first steps as searching, ties and all
+func TestNearestStepMatchesNearestPath(t *testing.T) {
+	g, err := GenerateMap(GenOptions{Vertices: 120}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(2))
+	nodes := g.Nodes()
+	for i := 0; i < 10; i++ {
+		g.AddPerson(Other, nodes[rng.Intn(len(nodes))])
+	}
+	goal := func(v *PositionedNode) bool { return len(v.People) > 0 }
+	for _, v := range nodes {
+		if got, want := g.nearestStep(v, goal), g.NearestPath(v, goal).FirstStep(); got != want {
+			t.Errorf("from %s the table steps to %v, searching to %v", v.Name, got, want)
+		}
+	}
+}
+
+// A larger town, with a few people scattered about and zombies everywhere
+func benchmarkTown(b *testing.B) (*MapGraph, func(*PositionedNode) bool) {
+	g, err := GenerateMap(GenOptions{Vertices: 500}, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	nodes := g.Nodes()
+	for i := 0; i < 20; i++ {
+		g.AddPerson(Other, nodes[rng.Intn(len(nodes))])
+	}
+	return g, func(v *PositionedNode) bool { return len(v.People) > 0 }
+}
+
+// One zombie's step on every vertex, searching each time
+func BenchmarkZombieStepSearch(b *testing.B) {
+	g, goal := benchmarkTown(b)
+	nodes := g.Nodes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range nodes {
+			g.nearestPath(v, goal).FirstStep()
+		}
+	}
+}
+
+// The same steps, looked up in the table
+func BenchmarkZombieStepTable(b *testing.B) {
+	g, goal := benchmarkTown(b)
+	nodes := g.Nodes()
+	g.pathTable()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range nodes {
+			g.nearestStep(v, goal)
+		}
+	}
+}
+
+func BenchmarkPathTableBuild(b *testing.B) {
+	g, _ := benchmarkTown(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newPathTable(g)
+	}
+}
+
+// Routes between random pairs of vertices in the town
+func benchmarkRoutes(b *testing.B) (*MapGraph, [][2]*PositionedNode) {
+	g, _ := benchmarkTown(b)
+	rng := rand.New(rand.NewSource(4))
+	nodes := g.Nodes()
+	routes := make([][2]*PositionedNode, 50)
+	for i := range routes {
+		routes[i] = [2]*PositionedNode{nodes[rng.Intn(len(nodes))], nodes[rng.Intn(len(nodes))]}
+	}
+	return g, routes
+}
+
+func BenchmarkRouteAStar(b *testing.B) {
+	g, routes := benchmarkRoutes(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range routes {
+			g.shortestPath(r[0], r[1])
+		}
+	}
+}
+
+// The same routes, by Dijkstra's algorithm
+func BenchmarkRouteDijkstra(b *testing.B) {
+	g, routes := benchmarkRoutes(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range routes {
+			to := r[1]
+			g.nearestPath(r[0], func(v *PositionedNode) bool { return v == to })
+		}
+	}
+}
+
+// Walking down the field gets to the nearest goal as quickly as searching for it
+func TestDistanceField(t *testing.T) {
+	g, err := GenerateMap(GenOptions{Vertices: 120}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(3))
+	for _, e := range g.Edges() {
+		e.W = float64(1 + rng.Intn(5))
+		g.SetEdge(e)
+	}
+	nodes := g.Nodes()
+	for i := 0; i < 6; i++ {
+		g.AddPerson(Other, nodes[rng.Intn(len(nodes))])
+	}
+
+	field := g.distanceField(hasPeople)
+	for _, v := range nodes {
+		want := g.NearestPath(v, hasPeople)
+		if want.Cost != field[v.Id] {
+			t.Errorf("field at %s is %g, want %g", v.Name, field[v.Id], want.Cost)
+		}
+		cost := 0.0
+		for at := v; !hasPeople(at); {
+			next := g.downhill(field, at)
+			if next == nil {
+				t.Fatalf("walking down from %s got stuck at %s", v.Name, at.Name)
+			}
+			cost += g.Edge(at, next).Weight()
+			at = next
+		}
+		if cost != want.Cost {
+			t.Errorf("walking down from %s costs %g, want %g", v.Name, cost, want.Cost)
+		}
+	}
+}
+
+// Every zombie's step on every vertex, from one field
+func BenchmarkZombieStepField(b *testing.B) {
+	g, goal := benchmarkTown(b)
+	nodes := g.Nodes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		field := g.distanceField(goal)
+		for _, v := range nodes {
+			g.downhill(field, v)
+		}
+	}
+}
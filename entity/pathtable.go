@@ -0,0 +1,193 @@
+package entity
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+
+	"github.com/gonum/graph"
+)
+
+/*
+** All-pairs shortest path distances and next hops, so that finding the way somewhere is a lookup rather than a search.
+** Built by running Dijkstra's algorithm from every vertex the first time it's needed, and thrown away whenever
+** a vertex or edge is added or removed, or an edge's weight is set. Edge weights must only be changed with SetEdge.
+**
+** Paths found here are the same as NearestPath would find, ties and all.
+ */
+
+type pathTable struct {
+	index map[int]int       // Row and column of each vertex ID
+	nodes []*PositionedNode // Vertex of each row, in order of ID
+	dist  [][]float64       // Infinite if there's no path
+	next  [][]int32         // Row of the first vertex after the start on the path, or -1
+}
+
+type pathCache struct {
+	mutex sync.Mutex
+	table *pathTable
+
+	// The A* heuristic's scale (see heuristicScale), if it's been worked out
+	scale  float64
+	scaled bool
+}
+
+// Dijkstra's algorithm from one vertex, keeping track of the first step instead of the whole path
+func (t *pathTable) fill(adjacent [][]int32, weights [][]float64, from int) {
+	dist := t.dist[from]
+	next := t.next[from]
+	for i := range dist {
+		dist[i] = math.Inf(1)
+		next[i] = -1
+	}
+	done := make([]bool, len(t.nodes))
+	dist[from] = 0
+	q := &pathQueue{{t.nodes[from], 0}}
+
+	for q.Len() > 0 {
+		v := t.index[heap.Pop(q).(pathItem).v.Id]
+		if done[v] {
+			continue
+		}
+		done[v] = true
+		for i, u := range adjacent[v] {
+			d := dist[v] + weights[v][i]
+			if !done[u] && d < dist[u] {
+				dist[u] = d
+				if v == from {
+					next[u] = u
+				} else {
+					next[u] = next[v]
+				}
+				heap.Push(q, pathItem{t.nodes[u], d})
+			}
+		}
+	}
+}
+
+func newPathTable(g *MapGraph) *pathTable {
+	nodes := g.Nodes()
+	t := &pathTable{make(map[int]int, len(nodes)), nodes, make([][]float64, len(nodes)), make([][]int32, len(nodes))}
+	for i, v := range nodes {
+		t.index[v.Id] = i
+	}
+
+	// Neighbours in order of ID, the same order NearestPath visits them in
+	adjacent := make([][]int32, len(nodes))
+	weights := make([][]float64, len(nodes))
+	for i, v := range nodes {
+		for _, u := range g.Neighbors(v) {
+			adjacent[i] = append(adjacent[i], int32(t.index[u.Id]))
+			weights[i] = append(weights[i], g.Edge(v, u).Weight())
+		}
+	}
+
+	for i := range nodes {
+		t.dist[i] = make([]float64, len(nodes))
+		t.next[i] = make([]int32, len(nodes))
+		t.fill(adjacent, weights, i)
+	}
+	return t
+}
+
+// The table for the graph as it is now, building it if it's been thrown away. Needs at least the read lock.
+func (g *MapGraph) pathTable() *pathTable {
+	g.paths.mutex.Lock()
+	defer g.paths.mutex.Unlock()
+	if g.paths.table == nil {
+		g.paths.table = newPathTable(g)
+	}
+	return g.paths.table
+}
+
+func (g *MapGraph) invalidatePaths() {
+	g.paths.mutex.Lock()
+	g.paths.table = nil
+	g.paths.scaled = false
+	g.paths.mutex.Unlock()
+}
+
+// Shortest distance between two vertices, and whether there's a path at all
+func (g *MapGraph) Distance(from *PositionedNode, to *PositionedNode) (float64, bool) {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	t := g.pathTable()
+	d := t.dist[t.index[from.Id]][t.index[to.Id]]
+	return d, !math.IsInf(d, 1)
+}
+
+// The first vertex after from on the shortest path to to, or nil if there's no path or they're the same vertex
+func (g *MapGraph) NextHop(from *PositionedNode, to *PositionedNode) *PositionedNode {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	t := g.pathTable()
+	next := t.next[t.index[from.Id]][t.index[to.Id]]
+	if next < 0 {
+		return nil
+	}
+	return t.nodes[next]
+}
+
+// The first step towards the nearest vertex satisfying goal, like NearestPath(from, goal).FirstStep(). Needs at least the read lock.
+func (g *MapGraph) nearestStep(from *PositionedNode, goal func(*PositionedNode) bool) *PositionedNode {
+	t := g.pathTable()
+	row := t.index[from.Id]
+	if goal(from) {
+		return nil
+	}
+	best := -1
+	for i, v := range t.nodes {
+		if !math.IsInf(t.dist[row][i], 1) && (best < 0 || t.dist[row][i] < t.dist[row][best]) && goal(v) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	return t.nodes[t.next[row][best]]
+}
+
+/*
+** The first step on the shortest path between two vertices, and whether there's a path which doesn't go through or to
+** any vertex for which avoid is true. If the shortest path does, there may still be a longer one that doesn't, which
+** this doesn't look for. Needs at least the read lock.
+ */
+func (g *MapGraph) clearStep(from *PositionedNode, to *PositionedNode, avoid func(*PositionedNode) bool) (*PositionedNode, bool) {
+	if from == to {
+		return nil, true
+	}
+	t := g.pathTable()
+	col, ok := t.index[to.Id]
+	if !ok {
+		return nil, false
+	}
+	first := t.next[t.index[from.Id]][col]
+	if first < 0 {
+		return nil, false
+	}
+	for v := first; ; v = t.next[v][col] {
+		if avoid(t.nodes[v]) {
+			return nil, false
+		}
+		if int(v) == col {
+			return t.nodes[first], true
+		}
+	}
+}
+
+// Changes to the graph which affect paths
+
+func (g *MapGraph) SetEdge(e graph.Edge) {
+	g.UndirectedGraph.SetEdge(e)
+	g.invalidatePaths()
+}
+
+func (g *MapGraph) RemoveEdge(e graph.Edge) {
+	g.UndirectedGraph.RemoveEdge(e)
+	g.invalidatePaths()
+}
+
+func (g *MapGraph) RemoveNode(n graph.Node) {
+	g.UndirectedGraph.RemoveNode(n)
+	g.invalidatePaths()
+}
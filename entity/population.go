@@ -0,0 +1,185 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+/*
+** Rule-based population generation, replacing whoever is on the map with people placed by rules like
+** "each vertex matching House * gets 0-3 people, 10% of them soldiers". For example:
+**
+** {
+**     "rules": [
+**         {"at": "House *", "min": 0, "max": 3, "professions": {"SOLDIER": 1, "OTHER": 9}},
+**         {"at": "Police Station", "min": 2, "max": 2, "professions": {"POLICE OFFICER": 1}},
+**         {"at": "*", "scatter": 15, "maxPerVertex": 2}
+**     ]
+** }
+**
+** Each rule either gives every vertex matching "at" between min and max people, or scatters that many people
+** across the matching vertices at random, skipping any which already have maxPerVertex people (if it is set).
+** Professions are drawn in proportion to their weights, and default to OTHER.
+ */
+
+type PopulationRules struct {
+	Rules []PopulationRule `json:"rules"`
+}
+
+type PopulationRule struct {
+	At           string         `json:"at"`
+	Min          int            `json:"min"`
+	Max          int            `json:"max"`
+	Scatter      int            `json:"scatter"`
+	MaxPerVertex int            `json:"maxPerVertex"`
+	Professions  map[string]int `json:"professions"`
+}
+
+func LoadPopulationRules(path string) (*PopulationRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := new(PopulationRules)
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+type weightedProfession struct {
+	profession Profession
+	weight     int
+}
+
+// Resolve profession names, in a fixed order so that a seed gives the same population
+func (r *PopulationRule) professions() ([]weightedProfession, int, error) {
+	if len(r.Professions) == 0 {
+		return []weightedProfession{{Other, 1}}, 1, nil
+	}
+
+	names := make([]string, 0, len(r.Professions))
+	for name := range r.Professions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := make([]weightedProfession, 0, len(names))
+	total := 0
+	for _, name := range names {
+		p, ok := ProfessionNamed(name)
+		if !ok {
+			return nil, 0, fmt.Errorf("no profession named %q", name)
+		}
+		if r.Professions[name] < 0 {
+			return nil, 0, fmt.Errorf("%s has a negative weight", name)
+		}
+		ret = append(ret, weightedProfession{p, r.Professions[name]})
+		total += r.Professions[name]
+	}
+	if total == 0 {
+		return nil, 0, fmt.Errorf("rule for %q has no professions with any weight", r.At)
+	}
+	return ret, total, nil
+}
+
+// A rule with its professions and vertices looked up
+type resolvedRule struct {
+	PopulationRule
+	jobs     []weightedProfession
+	total    int
+	vertices []*PositionedNode
+}
+
+func (r *resolvedRule) job(g *MapGraph) Profession {
+	n := g.Rand.Intn(r.total)
+	for _, j := range r.jobs {
+		if n < j.weight {
+			return j.profession
+		}
+		n -= j.weight
+	}
+	return Other
+}
+
+/*
+** Replace everyone on the map with people placed by the rules, using the graph's RNG.
+** Every rule is checked, and everyone placed, before anyone is removed, so a bad rule leaves the map as it was.
+ */
+func (rules *PopulationRules) Populate(g *MapGraph) error {
+	var resolved []resolvedRule
+	for _, r := range rules.Rules {
+		jobs, total, err := r.professions()
+		if err != nil {
+			return err
+		}
+		vertices, err := g.GetVerticesMatching(r.At)
+		if err != nil {
+			return fmt.Errorf("bad pattern %q: %s", r.At, err)
+		}
+		if len(vertices) == 0 {
+			return fmt.Errorf("no vertex matches %q", r.At)
+		}
+		if r.Scatter <= 0 && (r.Min < 0 || r.Max < r.Min) {
+			return fmt.Errorf("rule for %q needs 0 <= min <= max", r.At)
+		}
+		resolved = append(resolved, resolvedRule{r, jobs, total, vertices})
+	}
+
+	type placement struct {
+		job    Profession
+		vertex *PositionedNode
+	}
+	var placements []placement
+	// People on each vertex so far, by ID
+	placed := make(map[int]int)
+	for _, r := range resolved {
+		if r.Scatter > 0 {
+			open := r.vertices
+			for i := 0; i < r.Scatter; i++ {
+				if r.MaxPerVertex > 0 {
+					open = open[:0:0]
+					for _, v := range r.vertices {
+						if placed[v.Id] < r.MaxPerVertex {
+							open = append(open, v)
+						}
+					}
+					if len(open) == 0 {
+						return fmt.Errorf("no room to scatter %d people across %q", r.Scatter, r.At)
+					}
+				}
+				job := r.job(g)
+				v := open[g.Rand.Intn(len(open))]
+				placements = append(placements, placement{job, v})
+				placed[v.Id]++
+			}
+			continue
+		}
+
+		for _, v := range r.vertices {
+			for n := r.Min + g.Rand.Intn(r.Max-r.Min+1); n > 0; n-- {
+				placements = append(placements, placement{r.job(g), v})
+				placed[v.Id]++
+			}
+		}
+	}
+
+	g.Mutex.Lock()
+	for _, v := range g.Nodes() {
+		for _, p := range v.People {
+			p.leaveSquad()
+		}
+		v.People = nil
+	}
+	g.Changed = true
+	g.Mutex.Unlock()
+
+	for _, p := range placements {
+		g.AddPerson(p.job, p.vertex)
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+)
+
+/*
+** The profession registry, which works like the item registry. The built-in professions come first,
+** in the order of their constants, and professions loaded from a file (see LoadProfessions)
+** either replace a built-in profession of the same name, or are added after them.
+ */
+
+type ProfessionDef struct {
+	Name   string // Display name
+	Health int
+
+	// Hunger and thirst gained each tick
+	HungerRate uint
+	ThirstRate uint
+
+	// One draw is made from each table for a new person's starting items
+	Loadout []LoadoutTable
+}
+
+// A choice of starting items, drawn at random in proportion to their weights
+type LoadoutTable []LoadoutChoice
+
+type LoadoutChoice struct {
+	Weight int
+	Items  []Item // May be empty, for a chance of getting nothing
+}
+
+// Pick one choice from the table
+func (t LoadoutTable) draw(rng *rand.Rand) []Item {
+	if len(t) == 1 {
+		return t[0].Items
+	}
+	total := 0
+	for _, c := range t {
+		total += c.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	r := rng.Intn(total)
+	for _, c := range t {
+		if r < c.Weight {
+			return c.Items
+		}
+		r -= c.Weight
+	}
+	return nil
+}
+
+// Shorthand for the built-in tables
+func always(items ...Item) LoadoutTable {
+	return LoadoutTable{{1, items}}
+}
+
+func chance(in int, items ...Item) LoadoutTable {
+	return LoadoutTable{{1, items}, {in - 1, nil}}
+}
+
+var builtinProfessions = []ProfessionDef{
+	Police:      {"POLICE OFFICER", 100, 1, 1, []LoadoutTable{always(Pistol)}},
+	Firefighter: {"FIREFIGHTER", 100, 1, 1, []LoadoutTable{{{1, []Item{Chainsaw}}, {4, []Item{Hatchet}}}}},
+	Soldier:     {"SOLDIER", 100, 1, 1, []LoadoutTable{always(Rifle), {{12, nil}, {1, []Item{ATGM}}, {2, []Item{RPG}}}}},
+	Doctor:      {"DOCTOR", 100, 1, 1, []LoadoutTable{always(Bandage, Bandage, Bandage, Bandage, Hacksaw)}},
+	Engineer:    {"ENGINEER", 100, 1, 1, []LoadoutTable{always(Hatchet, Wrench)}},
+	Priest:      {"PRIEST", 100, 1, 1, []LoadoutTable{always(HolyWater)}},
+	Other:       {"OTHER", 100, 1, 1, nil},
+}
+
+// Drawn from for everyone, after their profession's tables
+var builtinCommonLoadout = []LoadoutTable{chance(3, EnergyBar), chance(2, WaterBottle), chance(10, RustyPipe)}
+
+var professions = append([]ProfessionDef(nil), builtinProfessions...)
+var commonLoadout = builtinCommonLoadout
+
+var invalidProfession = ProfessionDef{Name: "INVALID PROFESSION", Health: 100, HungerRate: 1, ThirstRate: 1}
+
+func (p Profession) def() *ProfessionDef {
+	if int(p) >= len(professions) {
+		return &invalidProfession
+	}
+	return &professions[p]
+}
+
+func (p Profession) String() string {
+	return p.def().Name
+}
+
+func (p Profession) Valid() bool {
+	return int(p) < len(professions)
+}
+
+// Every profession in the registry
+func Professions() []Profession {
+	ret := make([]Profession, len(professions))
+	for i := range professions {
+		ret[i] = Profession(i)
+	}
+	return ret
+}
+
+// The profession with the given display name (ignoring case), or Other if there isn't one
+func professionNamed(name string) Profession {
+	if p, ok := ProfessionNamed(name); ok {
+		return p
+	}
+	return Other
+}
+
+func ProfessionNamed(name string) (Profession, bool) {
+	for i, d := range professions {
+		if strings.EqualFold(d.Name, name) {
+			return Profession(i), true
+		}
+	}
+	return Other, false
+}
+
+func NewPerson(id uint, job Profession, pos int, rng *rand.Rand) *Person {
+	ret := &Person{Id: id, Health: job.def().Health, Items: make([]Item, 0, 2), Profession: job, Location: pos}
+	for _, t := range job.def().Loadout {
+		ret.AddItem(t.draw(rng)...)
+	}
+	for _, t := range commonLoadout {
+		ret.AddItem(t.draw(rng)...)
+	}
+	return ret
+}
+
+/*
+** The professions file. Items are given by name or abbreviation. Any field left out of a profession keeps
+** its built-in value, or takes the value for OTHER if it is a new profession. For example:
+**
+** {
+**     "common": [[{"weight": 1, "items": ["ENERGY BAR"]}, {"weight": 2}]],
+**     "professions": [
+**         {"name": "NURSE", "health": 90, "thirstRate": 2, "loadout": [[{"weight": 1, "items": ["BDG", "BDG"]}]]}
+**     ]
+** }
+ */
+
+type loadoutChoiceFile struct {
+	Weight int      `json:"weight"`
+	Items  []string `json:"items"`
+}
+
+type professionFile struct {
+	Name       string                `json:"name"`
+	Health     *int                  `json:"health"`
+	HungerRate *uint                 `json:"hungerRate"`
+	ThirstRate *uint                 `json:"thirstRate"`
+	Loadout    [][]loadoutChoiceFile `json:"loadout"`
+}
+
+type professionsFile struct {
+	Common      [][]loadoutChoiceFile `json:"common"` // Replaces the built-in tables, if given
+	Professions []professionFile      `json:"professions"`
+}
+
+func parseLoadout(tables [][]loadoutChoiceFile) ([]LoadoutTable, error) {
+	ret := make([]LoadoutTable, len(tables))
+	for i, t := range tables {
+		for _, c := range t {
+			choice := LoadoutChoice{Weight: c.Weight}
+			if choice.Weight <= 0 && len(t) > 1 {
+				return nil, fmt.Errorf("loadout choice %v needs a positive weight", c.Items)
+			}
+			for _, name := range c.Items {
+				item, ok := ItemNamed(name)
+				if !ok || item == Nothing {
+					return nil, fmt.Errorf("no item named %q", name)
+				}
+				choice.Items = append(choice.Items, item)
+			}
+			ret[i] = append(ret[i], choice)
+		}
+	}
+	return ret, nil
+}
+
+// Load profession definitions on top of the built-in ones. Items must already be loaded.
+func LoadProfessions(path string) error {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f professionsFile
+	err = json.Unmarshal(s, &f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	common := builtinCommonLoadout
+	if f.Common != nil {
+		common, err = parseLoadout(f.Common)
+		if err != nil {
+			return fmt.Errorf("%s: common loadout: %s", path, err)
+		}
+	}
+
+	registry := append([]ProfessionDef(nil), builtinProfessions...)
+	for _, pf := range f.Professions {
+		if pf.Name == "" {
+			return fmt.Errorf("%s: every profession needs a name", path)
+		}
+		name := strings.ToUpper(pf.Name)
+		existing := -1
+		for i := range registry {
+			if registry[i].Name == name {
+				existing = i
+			}
+		}
+		d := registry[Other]
+		if existing >= 0 {
+			d = registry[existing]
+		}
+		d.Name = name
+		if pf.Health != nil {
+			d.Health = *pf.Health
+		}
+		if pf.HungerRate != nil {
+			d.HungerRate = *pf.HungerRate
+		}
+		if pf.ThirstRate != nil {
+			d.ThirstRate = *pf.ThirstRate
+		}
+		if d.Health <= 0 {
+			return fmt.Errorf("%s: %s needs positive health", path, d.Name)
+		}
+		if pf.Loadout != nil {
+			d.Loadout, err = parseLoadout(pf.Loadout)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %s", path, d.Name, err)
+			}
+		}
+
+		if existing >= 0 {
+			registry[existing] = d
+		} else {
+			registry = append(registry, d)
+		}
+	}
+
+	professions = registry
+	commonLoadout = common
+	return nil
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLoadProfessions(t *testing.T) {
+	defer keepRegistries()()
+	if err := LoadItems("../items.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadProfessions("../professions.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if Police.String() != "POLICE OFFICER" || Other.String() != "OTHER" {
+		t.Errorf("the built-in professions moved")
+	}
+	nurse, ok := ProfessionNamed("nurse")
+	if !ok || nurse != Other+1 || nurse.def().Health != 90 {
+		t.Errorf("got %d for the nurse, want the first profession after the built-in ones, with 90 health", nurse)
+	}
+	hunter, ok := ProfessionNamed("HUNTER")
+	if !ok || hunter != nurse+1 || hunter.def().HungerRate != 2 {
+		t.Errorf("got %d for the hunter, want the next profession, getting hungry twice as fast", hunter)
+	}
+	if n := len(Professions()); n != int(hunter)+1 {
+		t.Errorf("got %d professions, want %d", n, hunter+1)
+	}
+
+	p := NewPerson(0, nurse, 0, rand.New(rand.NewSource(1)))
+	bandages := 0
+	for _, i := range p.Items {
+		if i == Bandage {
+			bandages++
+		}
+	}
+	if p.Health != 90 || bandages != 2 {
+		t.Errorf("got a nurse with %d health and %v, want 90 and two bandages", p.Health, p.Items)
+	}
+}
+
+func TestLoadProfessionsErrors(t *testing.T) {
+	defer keepRegistries()()
+	for _, data := range []string{
+		`{"professions": [{"health": 90}]}`,
+		`{"professions": [{"name": "NURSE", "health": 0}]}`,
+		`{"professions": [{"name": "NURSE", "loadout": [[{"weight": 1, "items": ["SCALPEL"]}]]}]}`,
+		`{"common": [[{"weight": 0, "items": ["BANDAGE"]}, {"weight": 1}]]}`,
+	} {
+		if err := loadTemp(t, LoadProfessions, data); err == nil {
+			t.Errorf("loaded %s", data)
+		}
+		if _, ok := ProfessionNamed("NURSE"); ok {
+			t.Errorf("a bad file still added professions")
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package entity
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+** Recordings are JSON lines. The first line is the map as it was when recording began,
+** in the same format as Serialize, and every following line is an Event.
+ */
+
+type Recorder struct {
+	out         *json.Encoder
+	err         error
+	unsubscribe func()
+}
+
+// Start recording every event on the graph to w
+func NewRecorder(w io.Writer, g *MapGraph) (*Recorder, error) {
+	s, err := g.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", s)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{out: json.NewEncoder(w)}
+	r.unsubscribe = g.Subscribe(func(e Event) {
+		if r.err == nil {
+			r.err = r.out.Encode(e)
+		}
+	})
+	return r, nil
+}
+
+// Stop recording, returning the first error encountered while writing, if any
+func (r *Recorder) Close() error {
+	r.unsubscribe()
+	return r.err
+}
+
+// Plays a recording back on a graph, in place of the live simulation
+type Replay struct {
+	g       *MapGraph
+	initial []byte
+	events  []Event
+	// Index of the next event to apply
+	next int
+}
+
+// Load a recording, setting the graph up as it was when recording began
+func NewReplay(r io.Reader, g *MapGraph) (*Replay, error) {
+	in := bufio.NewScanner(r)
+	// The map line can be long
+	in.Buffer(nil, 64*1024*1024)
+	if !in.Scan() {
+		if in.Err() != nil {
+			return nil, in.Err()
+		}
+		return nil, errors.New("empty recording")
+	}
+	replay := &Replay{g: g, initial: append([]byte(nil), in.Bytes()...)}
+
+	for in.Scan() {
+		var e Event
+		err := json.Unmarshal(in.Bytes(), &e)
+		if err != nil {
+			return nil, err
+		}
+		replay.events = append(replay.events, e)
+	}
+	if in.Err() != nil {
+		return nil, in.Err()
+	}
+
+	return replay, g.Deserialize(replay.initial)
+}
+
+// The tick of the last recorded event
+func (r *Replay) Length() uint64 {
+	if len(r.events) == 0 {
+		return 0
+	}
+	return r.events[len(r.events)-1].Time
+}
+
+// Play back as many ticks as fit in the real time elapsed, at the graph clock's speed
+func (r *Replay) Advance(elapsed time.Duration) {
+	for n := r.g.Clock.advance(elapsed); n > 0; n-- {
+		r.Step()
+	}
+}
+
+// Play back one tick, passing its events on to subscribers
+func (r *Replay) Step() {
+	if r.g.Clock.Ticks >= r.Length() {
+		return
+	}
+	r.g.Clock.Ticks++
+	r.applyUntil(r.g.Clock.Ticks, true)
+}
+
+// Jump to a tick. Subscribers don't see the events skipped over.
+func (r *Replay) Seek(tick uint64) {
+	if tick > r.Length() {
+		tick = r.Length()
+	}
+
+	// Going backwards means starting again from the beginning
+	if tick < r.g.Clock.Ticks {
+		clock := r.g.Clock
+		r.g.clear()
+		err := r.g.Deserialize(r.initial)
+		if err != nil {
+			// It loaded once already
+			panic(err)
+		}
+		r.g.Clock.Paused = clock.Paused
+		r.g.Clock.Speed = clock.Speed
+		r.next = 0
+	}
+
+	r.applyUntil(tick, false)
+	r.g.Clock.Ticks = tick
+}
+
+func (r *Replay) applyUntil(tick uint64, notify bool) {
+	for r.next < len(r.events) && r.events[r.next].Time <= tick {
+		r.g.apply(r.events[r.next])
+		if notify {
+			r.g.emit(r.events[r.next])
+		}
+		r.next++
+	}
+}
+
+// Remove every vertex, and everything on them
+func (g *MapGraph) clear() {
+	g.Mutex.Lock()
+	for _, n := range g.Nodes() {
+		g.RemoveNode(n)
+	}
+	g.entities = 0
+	g.Changed = true
+	g.Mutex.Unlock()
+}
+
+func findPerson(n *PositionedNode, id uint) *Person {
+	for _, p := range n.People {
+		if p.Id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func findZombie(n *PositionedNode, id uint) *Zombie {
+	for _, z := range n.Zombies {
+		if z.Id == id {
+			return z
+		}
+	}
+	return nil
+}
+
+// Make the change to the map described by a recorded event
+func (g *MapGraph) apply(e Event) {
+	n := g.Node(e.Vertex)
+	switch e.Kind {
+	case Spawn:
+		if e.Actor.Zombie {
+			n.Zombies = append(n.Zombies, NewZombie(e.Actor.Id, n.ID()))
+		} else {
+			job := professionNamed(e.Actor.Name)
+			n.People = append(n.People, &Person{Id: e.Actor.Id, Health: job.def().Health, Items: append([]Item(nil), e.Items...), Profession: job, Location: n.ID()})
+		}
+	case Attack:
+		if e.Target.Zombie {
+			if z := findZombie(n, e.Target.Id); z != nil {
+				z.Health = e.Health
+			}
+		} else if p := findPerson(n, e.Target.Id); p != nil {
+			p.Health = e.Health
+		}
+	case Infection:
+		if p := findPerson(n, e.Target.Id); p != nil {
+			g.RemovePerson(p)
+			z := NewZombie(p.Id, n.ID())
+			z.Holding = e.Item
+			n.Zombies = append(n.Zombies, z)
+		}
+	case Death:
+		if e.Actor.Zombie {
+			if z := findZombie(n, e.Actor.Id); z != nil {
+				g.RemoveZombie(z)
+			}
+		} else if p := findPerson(n, e.Actor.Id); p != nil {
+			g.RemovePerson(p)
+		}
+	case Move:
+		if e.Actor.Zombie {
+			if z := findZombie(n, e.Actor.Id); z != nil {
+				z.moveTo(g, g.Node(e.To))
+			}
+		} else if p := findPerson(n, e.Actor.Id); p != nil {
+			p.moveTo(g, g.Node(e.To))
+		}
+	case Pickup:
+		for i, item := range n.Items {
+			if item == e.Item {
+				n.Items = append(n.Items[:i], n.Items[i+1:]...)
+				break
+			}
+		}
+		if e.Actor.Zombie {
+			if z := findZombie(n, e.Actor.Id); z != nil {
+				z.Holding = e.Item
+			}
+		} else if p := findPerson(n, e.Actor.Id); p != nil {
+			p.AddItem(e.Item)
+		}
+	case Consume:
+		if e.Actor.Zombie {
+			if z := findZombie(n, e.Actor.Id); z != nil {
+				z.Holding = Nothing
+			}
+		} else if p := findPerson(n, e.Actor.Id); p != nil && p.Holding(e.Item) {
+			p.ConsumeItem(e.Item)
+		}
+	case Share:
+		from, to := findPerson(n, e.Actor.Id), findPerson(n, e.Target.Id)
+		if from != nil && to != nil && from.Holding(e.Item) {
+			from.ConsumeItem(e.Item)
+			to.AddItem(e.Item)
+		}
+	}
+	g.Changed = true
+}
@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// Who is where, by ID, and what is lying about
+func whereabouts(g *MapGraph) string {
+	var b bytes.Buffer
+	for _, v := range g.Nodes() {
+		fmt.Fprintf(&b, "%d:", v.Id)
+		for _, p := range v.People {
+			fmt.Fprintf(&b, " p%d", p.Id)
+		}
+		for _, z := range v.Zombies {
+			fmt.Fprintf(&b, " z%d", z.Id)
+		}
+		fmt.Fprintf(&b, " %v\n", v.Items)
+	}
+	return b.String()
+}
+
+func TestReplaySeek(t *testing.T) {
+	g := snapshotWorld(t)
+	var out bytes.Buffer
+	recorder, err := NewRecorder(&out, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[uint64]string)
+	for i := 0; i < 80; i++ {
+		g.Step()
+		want[g.Clock.Ticks] = whereabouts(g)
+	}
+	if err = recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want[20] == want[60] {
+		t.Fatalf("nothing happened between ticks 20 and 60, so seeking tests nothing")
+	}
+
+	r := NewMapGraph(R(0, 0, 1000, 1000), 25)
+	replay, err := NewReplay(&out, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []Event
+	r.Subscribe(func(e Event) { seen = append(seen, e) })
+
+	// Forwards, back to the start, and forwards again from there
+	for _, tick := range []uint64{60, 20, 21} {
+		replay.Seek(tick)
+		if r.Clock.Ticks != tick {
+			t.Errorf("seeking to %d got to %d", tick, r.Clock.Ticks)
+		}
+		if got := whereabouts(r); got != want[tick] {
+			t.Errorf("after seeking to tick %d got\n%s\nwant\n%s", tick, got, want[tick])
+		}
+	}
+	if len(seen) > 0 {
+		t.Errorf("subscribers saw %d events skipped over", len(seen))
+	}
+
+	// Stepping on from a seek carries on as recorded
+	replay.Step()
+	if got := whereabouts(r); got != want[22] {
+		t.Errorf("after stepping to tick 22 got\n%s\nwant\n%s", got, want[22])
+	}
+	replay.Seek(1000)
+	if r.Clock.Ticks != replay.Length() {
+		t.Errorf("seeking past the end got to %d, want %d", r.Clock.Ticks, replay.Length())
+	}
+}
@@ -0,0 +1,262 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+/*
+** Scenarios set up the starting conditions of an outbreak on a map, so one map can be used with many of them.
+** For example:
+**
+** {
+**     "name": "Patient zero at the Resort",
+**     "map": "map.json",
+**     "people": [
+**         {"profession": "SOLDIER", "count": 2, "at": "Barracks"},
+**         {"profession": "OTHER", "count": 2, "at": "House *", "each": true},
+**         {"profession": "DOCTOR", "count": 1, "at": "*", "items": ["BANDAGE"]}
+**     ],
+**     "zombies": [{"count": 1, "at": "Resort", "holding": "RUSTY PIPE"}],
+**     "items": [{"item": "ENERGY BAR", "count": 10, "at": "Store *"}]
+** }
+**
+** The map is relative to the scenario file. Unless keepMapPopulation is set, the people, zombies and items
+** saved in the map are removed first, apart from fixed items like water sources.
+**
+** "at" is the name of a vertex, or a shell pattern matching several. Each rule places count people, zombies
+** or items on vertices chosen at random from those matching, or count on every one of them if "each" is set.
+** People get their profession's starting items, unless "items" is given. Professions and items are given by name.
+ */
+
+type Scenario struct {
+	Name              string `json:"name"`
+	Map               string `json:"map"`
+	KeepMapPopulation bool   `json:"keepMapPopulation"`
+
+	People  []PeopleRule `json:"people"`
+	Zombies []ZombieRule `json:"zombies"`
+	Items   []ItemRule   `json:"items"`
+}
+
+// Where to put things
+type Placement struct {
+	Count int    `json:"count"`
+	At    string `json:"at"`
+	Each  bool   `json:"each"`
+}
+
+type PeopleRule struct {
+	Placement
+	Profession string   `json:"profession"`
+	Items      []string `json:"items"`
+}
+
+type ZombieRule struct {
+	Placement
+	Holding string `json:"holding"`
+}
+
+type ItemRule struct {
+	Placement
+	Item string `json:"item"`
+}
+
+// Read a scenario file, making its map path relative to the working directory
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(Scenario)
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if s.Map != "" && !filepath.IsAbs(s.Map) {
+		s.Map = filepath.Join(filepath.Dir(path), s.Map)
+	}
+	return s, nil
+}
+
+// The vertices to put each of the things placed by a rule on
+func (p Placement) vertices(g *MapGraph) ([]*PositionedNode, error) {
+	if p.Count < 0 {
+		return nil, fmt.Errorf("negative count %d at %q", p.Count, p.At)
+	}
+	matching, err := g.GetVerticesMatching(p.At)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %s", p.At, err)
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no vertex matches %q", p.At)
+	}
+
+	if p.Each {
+		ret := make([]*PositionedNode, 0, p.Count*len(matching))
+		for _, v := range matching {
+			for i := 0; i < p.Count; i++ {
+				ret = append(ret, v)
+			}
+		}
+		return ret, nil
+	}
+
+	ret := make([]*PositionedNode, p.Count)
+	for i := range ret {
+		ret[i] = matching[g.Rand.Intn(len(matching))]
+	}
+	return ret, nil
+}
+
+func itemsNamed(names []string) ([]Item, error) {
+	var ret []Item
+	for _, name := range names {
+		item, ok := ItemNamed(name)
+		if !ok || item == Nothing {
+			return nil, fmt.Errorf("no item named %q", name)
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+// Set the scenario up on a graph which has its map loaded. Placement uses the graph's RNG, so a seed reproduces it.
+func (s *Scenario) Apply(g *MapGraph) error {
+	if !s.KeepMapPopulation {
+		g.Mutex.Lock()
+		for _, v := range g.Nodes() {
+			v.People = nil
+			v.Zombies = nil
+			var fixed []Item
+			for _, i := range v.Items {
+				if i.Fixed() {
+					fixed = append(fixed, i)
+				}
+			}
+			v.Items = fixed
+		}
+		g.Changed = true
+		g.Mutex.Unlock()
+	}
+
+	for _, r := range s.People {
+		job, ok := ProfessionNamed(r.Profession)
+		if !ok {
+			return fmt.Errorf("no profession named %q", r.Profession)
+		}
+		items, err := itemsNamed(r.Items)
+		if err != nil {
+			return err
+		}
+		if r.Items != nil && items == nil {
+			// Given, but empty, so they carry nothing
+			items = []Item{}
+		}
+		vertices, err := r.vertices(g)
+		if err != nil {
+			return err
+		}
+		for _, v := range vertices {
+			g.addPerson(job, v, items)
+		}
+	}
+
+	for _, r := range s.Zombies {
+		holding := Nothing
+		if r.Holding != "" {
+			var ok bool
+			holding, ok = ItemNamed(r.Holding)
+			if !ok {
+				return fmt.Errorf("no item named %q", r.Holding)
+			}
+		}
+		vertices, err := r.vertices(g)
+		if err != nil {
+			return err
+		}
+		for _, v := range vertices {
+			g.AddNewZombie(v).Holding = holding
+		}
+	}
+
+	for _, r := range s.Items {
+		items, err := itemsNamed([]string{r.Item})
+		if err != nil {
+			return err
+		}
+		vertices, err := r.vertices(g)
+		if err != nil {
+			return err
+		}
+		g.Mutex.Lock()
+		for _, v := range vertices {
+			v.Items = append(v.Items, items[0])
+		}
+		g.Changed = true
+		g.Mutex.Unlock()
+	}
+
+	return nil
+}
+
+// A vertex name as a pattern which only matches itself
+func escapePattern(name string) string {
+	for _, c := range []string{`\`, "*", "?", "["} {
+		name = strings.Replace(name, c, `\`+c, -1)
+	}
+	return name
+}
+
+// A scenario which sets up the people, zombies and items on the graph exactly as they are now, on the map at mapPath
+func (g *MapGraph) Scenario(name string, mapPath string) *Scenario {
+	s := &Scenario{Name: name, Map: filepath.ToSlash(mapPath)}
+
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	for _, v := range g.Nodes() {
+		at := escapePattern(v.Name)
+
+		// Identical people on a vertex share a rule
+		for _, p := range v.People {
+			items := make([]string, len(p.Items))
+			for i, item := range p.Items {
+				items[i] = item.StringLong()
+			}
+			last := len(s.People) - 1
+			if last >= 0 && s.People[last].At == at && s.People[last].Profession == p.Profession.String() && strings.Join(s.People[last].Items, ",") == strings.Join(items, ",") {
+				s.People[last].Count++
+			} else {
+				s.People = append(s.People, PeopleRule{Placement{1, at, false}, p.Profession.String(), items})
+			}
+		}
+
+		for _, z := range v.Zombies {
+			holding := ""
+			if z.Holding != Nothing {
+				holding = z.Holding.StringLong()
+			}
+			s.Zombies = append(s.Zombies, ZombieRule{Placement{1, at, false}, holding})
+		}
+
+		counts := make(map[Item]int)
+		var order []Item
+		for _, i := range v.Items {
+			if i.Fixed() {
+				continue
+			}
+			if counts[i] == 0 {
+				order = append(order, i)
+			}
+			counts[i]++
+		}
+		for _, i := range order {
+			s.Items = append(s.Items, ItemRule{Placement{counts[i], at, false}, i.StringLong()})
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"sort"
+	"time"
+)
+
+/*
+** Discrete-time scheduling. Every entity acts once per tick, in a fixed order,
+** so a run depends only on the map and the seed, and can go as fast as the CPU allows.
+** Step and Advance must only be called from one goroutine at a time.
+ */
+
+// Length of a tick in real time, at normal speed
+const TickDuration = 500 * time.Millisecond
+
+// Entities wait up to this many ticks before first acting, so they don't all move in lockstep
+const maxStartDelay = 4
+
+// Travel times are given in seconds
+func secondsToTicks(s float64) int {
+	return int(s * float64(time.Second/TickDuration))
+}
+
+// Stagger the first actions of everyone already on the map
+func (g *MapGraph) StartEntities() {
+	for _, v := range g.Nodes() {
+		for _, p := range v.People {
+			p.wait = g.Rand.Intn(maxStartDelay)
+		}
+
+		for _, z := range v.Zombies {
+			z.wait = g.Rand.Intn(maxStartDelay)
+		}
+	}
+}
+
+/*
+** Advance the simulation by one tick.
+** People act first, then zombies, each in order of ID.
+** Anyone created during the tick (e.g., by infection) first acts on the next one.
+** People all run from zombies by where they were at the start of the tick,
+** and zombies all chase people by where they were once the people had acted.
+** Squads form first, from whoever is idle at the start of the tick.
+ */
+func (g *MapGraph) Step() {
+	g.Clock.Ticks++
+	g.formSquads()
+
+	var people []*Person
+	var zombies []*Zombie
+	for _, v := range g.Nodes() {
+		people = append(people, v.People...)
+		zombies = append(zombies, v.Zombies...)
+	}
+	sort.SliceStable(people, func(i, j int) bool { return people[i].Id < people[j].Id })
+	sort.SliceStable(zombies, func(i, j int) bool { return zombies[i].Id < zombies[j].Id })
+
+	g.updateThreatField()
+	for _, p := range people {
+		p.Tick(g)
+	}
+	g.threats = nil
+	g.updateHumanField()
+	for _, z := range zombies {
+		z.Tick(g)
+	}
+	g.humans = nil
+}
+
+// Run as many ticks as fit in the real time elapsed since the last call, at the clock's speed
+func (g *MapGraph) Advance(elapsed time.Duration) {
+	for n := g.Clock.advance(elapsed); n > 0; n-- {
+		g.Step()
+	}
+}
@@ -0,0 +1,129 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gonum/graph/simple"
+)
+
+/*
+** Snapshots hold the complete live state of a simulation, so it can be resumed later.
+** The map is stored as by Serialize (which covers people, zombies, items and the clock),
+** alongside the state of the simulation which isn't part of a map.
+ */
+type snapshot struct {
+	Map      json.RawMessage
+	Entities uint // Next entity ID to hand out
+
+	// The RNG's seed, and how many numbers it had given out since, so a restored simulation
+	// carries on exactly as the original did. Taking a snapshot doesn't touch the RNG.
+	Seed  int64
+	Draws uint64
+
+	// Entities part way through travelling, with damage yet to be taken, or heading for something they need
+	Busy []busyState
+}
+
+type busyState struct {
+	Id     uint
+	Zombie bool
+	Wait   int
+	Dest   int // -1 if not travelling
+	Damage []DamageMessage
+	Goal   *int `json:",omitempty"` // Vertex a person is heading for, if any
+
+	Departed uint64 `json:",omitempty"` // Tick a person last set off on
+}
+
+func newBusyState(id uint, zombie bool, wait int, dest *PositionedNode, damage []DamageMessage) busyState {
+	b := busyState{id, zombie, wait, -1, damage, nil, 0}
+	if dest != nil {
+		b.Dest = dest.ID()
+	}
+	return b
+}
+
+func (g *MapGraph) Snapshot() ([]byte, error) {
+	s := snapshot{Entities: g.entities}
+	s.Seed, s.Draws = g.source.state()
+
+	var err error
+	s.Map, err = g.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range g.Nodes() {
+		for _, p := range v.People {
+			if p.wait > 0 || len(p.damage) > 0 || p.goal != nil {
+				b := newBusyState(p.Id, false, p.wait, p.dest, p.damage)
+				if p.goal != nil {
+					goal := p.goal.ID()
+					b.Goal = &goal
+				}
+				b.Departed = p.departed
+				s.Busy = append(s.Busy, b)
+			}
+		}
+		for _, z := range v.Zombies {
+			if z.wait > 0 || len(z.damage) > 0 {
+				s.Busy = append(s.Busy, newBusyState(z.Id, true, z.wait, z.dest, z.damage))
+			}
+		}
+	}
+
+	return json.Marshal(s)
+}
+
+// Replace everything on the graph with the contents of a snapshot
+func (g *MapGraph) Restore(data []byte) error {
+	var s snapshot
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	if s.Map == nil {
+		return errors.New("not a snapshot")
+	}
+
+	g.clear()
+	err = g.Deserialize(s.Map)
+	if err != nil {
+		return err
+	}
+	g.entities = s.Entities
+	g.source.restore(s.Seed, s.Draws)
+
+	vertex := func(id int) (*PositionedNode, error) {
+		if !g.Has(simple.Node(id)) {
+			return nil, fmt.Errorf("snapshot: unknown vertex %d", id)
+		}
+		return g.Node(id), nil
+	}
+	for _, b := range s.Busy {
+		var dest, goal *PositionedNode
+		if b.Dest >= 0 {
+			if dest, err = vertex(b.Dest); err != nil {
+				return err
+			}
+		}
+		if b.Goal != nil {
+			if goal, err = vertex(*b.Goal); err != nil {
+				return err
+			}
+		}
+		for _, v := range g.Nodes() {
+			if b.Zombie {
+				if z := findZombie(v, b.Id); z != nil {
+					z.wait, z.dest, z.damage = b.Wait, dest, b.Damage
+				}
+			} else if p := findPerson(v, b.Id); p != nil {
+				p.wait, p.dest, p.damage, p.goal, p.departed = b.Wait, dest, b.Damage, goal, b.Departed
+			}
+		}
+	}
+
+	return nil
+}
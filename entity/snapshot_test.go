@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// A generated town with people and zombies scattered over it, the same every time
+func snapshotWorld(t *testing.T) *MapGraph {
+	g, err := GenerateMap(GenOptions{Vertices: 60}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Seed(1)
+	rules := PopulationRules{[]PopulationRule{{At: "*", Scatter: 30}}}
+	if err = rules.Populate(g); err != nil {
+		t.Fatal(err)
+	}
+	nodes := g.Nodes()
+	for i := 0; i < 6; i++ {
+		g.AddNewZombie(nodes[g.Rand.Intn(len(nodes))])
+	}
+	return g
+}
+
+// Everything that happens in the next n ticks
+func record(g *MapGraph, n int) []Event {
+	var events []Event
+	unsubscribe := g.Subscribe(func(e Event) { events = append(events, e) })
+	for i := 0; i < n; i++ {
+		g.Step()
+	}
+	unsubscribe()
+	return events
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	const before, after = 40, 60
+
+	u := snapshotWorld(t)
+	record(u, before)
+	want := record(u, after)
+
+	// Carrying on after taking a snapshot is the same as never taking one
+	g := snapshotWorld(t)
+	record(g, before)
+	data, err := g.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := record(g, after); !reflect.DeepEqual(got, want) {
+		t.Fatalf("taking a snapshot changed what happened next")
+	}
+
+	var s snapshot
+	if err = json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Busy) == 0 {
+		t.Fatalf("nobody was busy at tick %d, so the snapshot tests nothing", before)
+	}
+
+	// And so is restoring it, even into a different graph
+	r := NewMapGraph(Rect{}, 0)
+	if err = r.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := record(r, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("a restored snapshot went differently to the original run")
+	}
+}
+
+func TestSnapshotUnknownVertex(t *testing.T) {
+	g := snapshotWorld(t)
+	record(g, 40)
+	data, err := g.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s snapshot
+	if err = json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	s.Busy[0].Dest = 1000
+	if data, err = json.Marshal(s); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Restore(data); err == nil || err.Error() != "snapshot: unknown vertex 1000" {
+		t.Errorf("got %v restoring a snapshot naming a missing vertex", err)
+	}
+}
@@ -0,0 +1,301 @@
+package entity
+
+import (
+	"sort"
+)
+
+/*
+** Squads. People idle on the same vertex band together, up to SquadSize of them, and stay together
+** until death or infection takes members out. The leader decides where the squad goes, and everyone
+** goes with them. Everyone in a squad goes for the same zombie, and they hand water bottles and
+** energy bars round to whoever needs them.
+ */
+
+type Squad struct {
+	Leader  *Person
+	Members []*Person // Including the leader, in order of ID
+
+	// The zombie the squad is fighting, and the tick it was picked on
+	target   *Zombie
+	targeted uint64
+}
+
+func (p *Person) Squad() *Squad {
+	return p.squad
+}
+
+// Whether p decides where they go: on their own, or leading a squad
+func (p *Person) leads() bool {
+	return p.squad == nil || p.squad.Leader == p
+}
+
+func (s *Squad) add(p *Person) {
+	p.squad = s
+	s.Members = append(s.Members, p)
+	sort.Slice(s.Members, func(i, j int) bool { return s.Members[i].Id < s.Members[j].Id })
+}
+
+// The best armed member leads, or the lowest ID if it's a tie
+func (s *Squad) chooseLeader() {
+	s.Leader = s.Members[0]
+	for _, m := range s.Members[1:] {
+		if m.BestWeapon().Damage() > s.Leader.BestWeapon().Damage() {
+			s.Leader = m
+		}
+	}
+}
+
+// Take someone dead or infected out of their squad, which breaks up if only one member is left
+func (p *Person) leaveSquad() {
+	s := p.squad
+	if s == nil {
+		return
+	}
+	p.squad = nil
+	for i, m := range s.Members {
+		if m == p {
+			s.Members = append(s.Members[:i], s.Members[i+1:]...)
+			break
+		}
+	}
+
+	if len(s.Members) < 2 {
+		for _, m := range s.Members {
+			m.squad = nil
+		}
+		s.Members = nil
+		s.Leader = nil
+		return
+	}
+	if s.Leader == p {
+		s.chooseLeader()
+	}
+}
+
+func (p *Person) idle() bool {
+	return !p.dead && p.wait == 0 && p.dest == nil
+}
+
+// Band together everyone idle on the same vertex, topping up the squads already there first
+func (g *MapGraph) formSquads() {
+	if g.Tunables.SquadSize < 2 {
+		return
+	}
+	for _, v := range g.Nodes() {
+		var squads []*Squad
+		var alone []*Person
+		for _, p := range v.People {
+			if !p.idle() {
+				continue
+			}
+			if p.squad == nil {
+				alone = append(alone, p)
+			} else if p.squad.Leader == p {
+				squads = append(squads, p.squad)
+			}
+		}
+		sort.Slice(squads, func(i, j int) bool { return squads[i].Leader.Id < squads[j].Leader.Id })
+		sort.Slice(alone, func(i, j int) bool { return alone[i].Id < alone[j].Id })
+
+		for _, s := range squads {
+			for len(alone) > 0 && len(s.Members) < g.Tunables.SquadSize {
+				s.add(alone[0])
+				alone = alone[1:]
+			}
+		}
+		// Anyone left on their own waits for someone else to turn up
+		for len(alone) >= 2 {
+			s := new(Squad)
+			for len(alone) > 0 && len(s.Members) < g.Tunables.SquadSize {
+				s.add(alone[0])
+				alone = alone[1:]
+			}
+			s.chooseLeader()
+		}
+	}
+}
+
+// Whether p, or anyone in their squad, has something to fight with
+func (p *Person) squadArmed() bool {
+	if p.squad == nil {
+		return p.armed()
+	}
+	for _, m := range p.squad.Members {
+		if m.armed() {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+** The zombie to attack on n. Alone, that's the weakest. A squad goes for the healthiest zombie its members
+** can finish off between them this tick, or the weakest if there isn't one. It's picked once per tick,
+** so members using up their weapons doesn't change it for the rest.
+ */
+func (p *Person) target(g *MapGraph, n *PositionedNode) *Zombie {
+	weakest := n.Zombies[0]
+	for _, z := range n.Zombies {
+		if z.Health < weakest.Health {
+			weakest = z
+		}
+	}
+	s := p.squad
+	if s == nil {
+		return weakest
+	}
+	if s.targeted == g.Clock.Ticks && s.target != nil && !s.target.dead && s.target.Location == n.Id {
+		return s.target
+	}
+
+	damage := 0
+	for _, m := range s.Members {
+		if m.Location == n.Id && m.idle() {
+			damage += int(m.BestWeapon().Damage())
+		}
+	}
+	s.target, s.targeted = weakest, g.Clock.Ticks
+	for _, z := range n.Zombies {
+		if z.Health <= damage && z.Health > s.target.Health {
+			s.target = z
+		}
+	}
+	return s.target
+}
+
+// Everyone setting off with p: their whole squad, if they lead one
+func (p *Person) travellers() []*Person {
+	if p.squad == nil || p.squad.Leader != p {
+		return []*Person{p}
+	}
+	var ret []*Person
+	for _, m := range p.squad.Members {
+		if m.Location == p.Location && !m.dead && m.dest == nil {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// Set off for a neighbouring vertex, taking the squad along. Flights are reported as they start.
+func (p *Person) setOff(g *MapGraph, from *PositionedNode, t *PositionedNode, flee bool) {
+	for _, m := range p.travellers() {
+		m.travel(g, from, t)
+		// Anyone yet to act this tick mustn't count it towards the journey, or they'd get there first
+		m.departed = g.Clock.Ticks
+		if flee {
+			g.emit(Event{Kind: Flee, Actor: m.actor(), Vertex: from.ID(), To: t.ID(), Value: m.wait})
+		}
+		if m.wait == 0 {
+			m.arrive(g)
+		}
+	}
+}
+
+func (s *Squad) holding(item Item) bool {
+	for _, m := range s.Members {
+		if m.Holding(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// The most pressing need of anyone in the squad, as for need. What the others carry counts, since they'll share it.
+func (s *Squad) need(g *MapGraph) func(Item) bool {
+	for _, m := range s.Members {
+		if m.thirsty() && !s.holding(WaterBottle) {
+			return m.wantsWater(g)
+		}
+	}
+	for _, m := range s.Members {
+		if m.hungry() && !s.holding(EnergyBar) {
+			return m.wantsFood(g)
+		}
+	}
+	for _, m := range s.Members {
+		if !m.armed() {
+			return m.wantsWeapon(g)
+		}
+	}
+	return nil
+}
+
+// Whether anyone in the squad is about to pick up something they need from n, so the leader should wait for them
+func (s *Squad) gathering(g *MapGraph, n *PositionedNode) bool {
+	for _, m := range s.Members {
+		if m == s.Leader || m.Location != n.Id {
+			continue
+		}
+		if wants := m.need(g); wants != nil {
+			for _, item := range n.Items {
+				if wants(item) && !item.Fixed() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Have a squad-mate hand p one of item, unless it's their last and they need it themselves. Returns whether anyone did.
+func (p *Person) askSquad(g *MapGraph, item Item) bool {
+	if p.squad == nil {
+		return false
+	}
+	for _, m := range p.squad.Members {
+		if m == p || m.Location != p.Location || !m.spare(item) {
+			continue
+		}
+		m.ConsumeItem(item)
+		p.AddItem(item)
+		g.Changed = true
+		g.emit(Event{Kind: Share, Actor: m.actor(), Target: p.actor(), Vertex: p.Location, To: -1, Item: item})
+		return true
+	}
+	return false
+}
+
+func (p *Person) spare(item Item) bool {
+	n := 0
+	for _, i := range p.Items {
+		if i == item {
+			n++
+		}
+	}
+	switch {
+	case n == 0:
+		return false
+	case n > 1:
+		return true
+	case item == WaterBottle:
+		return !p.thirsty()
+	case item == EnergyBar:
+		return !p.hungry()
+	}
+	return true
+}
+
+// Rebuild squads from the leaders given in a map file, by person ID
+func (g *MapGraph) loadSquads(leaders map[uint]uint) {
+	for _, v := range g.Nodes() {
+		squads := make(map[uint]*Squad)
+		for _, p := range v.People {
+			if l, ok := leaders[p.Id]; ok && findPerson(v, l) != nil {
+				if squads[l] == nil {
+					squads[l] = new(Squad)
+				}
+				squads[l].add(p)
+			}
+		}
+		for l, s := range squads {
+			s.Leader = findPerson(v, l)
+			if s.Leader.squad != s || len(s.Members) < 2 {
+				// Not a squad after all
+				for _, m := range s.Members {
+					m.squad = nil
+				}
+			}
+		}
+	}
+}
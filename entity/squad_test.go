@@ -0,0 +1,193 @@
+package entity
+
+import (
+	"testing"
+)
+
+// Put n people on v, carrying nothing
+func squadPeople(g *MapGraph, v *PositionedNode, n int) []*Person {
+	var people []*Person
+	for i := 0; i < n; i++ {
+		p := g.AddPerson(Other, v)
+		p.Items = nil
+		people = append(people, p)
+	}
+	return people
+}
+
+func TestSquadsForm(t *testing.T) {
+	g := plannerGraph()
+	people := squadPeople(g, g.Node(0), 5)
+	people[2].Items = []Item{Pistol}
+	g.formSquads()
+
+	s := people[0].Squad()
+	if s == nil || len(s.Members) != 4 || s.Leader != people[2] {
+		t.Fatalf("got squad %+v, want the first four, led by the one with the pistol", s)
+	}
+	if people[4].Squad() != nil {
+		t.Errorf("a fifth person joined a full squad")
+	}
+
+	// The next to turn up joins the one left over
+	p := g.AddPerson(Other, g.Node(0))
+	g.formSquads()
+	if p.Squad() == nil || p.Squad() != people[4].Squad() || p.Squad() == s {
+		t.Errorf("the two left over didn't form a squad of their own")
+	}
+}
+
+func TestSquadTravelsTogether(t *testing.T) {
+	// 0 - 1, a couple of ticks apart, with a rifle at 1 for the one with nothing to fight with
+	g := testGraph([]Vec{V(0, 0), V(10, 0)}, []testEdge{{0, 1, 2}})
+	g.Node(1).Items = []Item{Rifle}
+	people := squadPeople(g, g.Node(0), 3)
+	people[0].Items = []Item{Pistol}
+	people[2].Items = []Item{Pistol}
+
+	arrived := make(map[uint]uint64)
+	g.Subscribe(func(e Event) {
+		if e.Kind == Move && !e.Actor.Zombie {
+			arrived[e.Actor.Id] = e.Time
+		}
+	})
+	for i := 0; i < 20 && !people[1].Holding(Rifle); i++ {
+		g.Step()
+	}
+
+	if !people[1].Holding(Rifle) {
+		t.Fatalf("the squad never fetched the rifle")
+	}
+	for _, p := range people {
+		if p.Location != 1 {
+			t.Errorf("%d is at %d, want everyone at 1", p.Id, p.Location)
+		}
+		if arrived[p.Id] != arrived[people[0].Id] {
+			t.Errorf("arrivals at %v, want everyone at once", arrived)
+		}
+	}
+}
+
+// A squad which can finish off a zombie between them goes for it, rather than just the weakest
+func TestSquadPoolsCombat(t *testing.T) {
+	g := plannerGraph()
+	people := squadPeople(g, g.Node(0), 2)
+	people[0].Items = []Item{Pistol}
+	people[1].Items = []Item{Hatchet}
+	weakest, killable, healthy := g.AddNewZombie(g.Node(0)), g.AddNewZombie(g.Node(0)), g.AddNewZombie(g.Node(0))
+	weakest.Health, killable.Health, healthy.Health = 10, int(Pistol.Damage()+Hatchet.Damage()), 100
+	// Attacks land when the zombies next act
+	weakest.wait, killable.wait, healthy.wait = 0, 0, 0
+
+	var targets []uint
+	g.Subscribe(func(e Event) {
+		if e.Kind == Attack && !e.Actor.Zombie {
+			targets = append(targets, e.Target.Id)
+		}
+	})
+	g.Step()
+	if len(targets) != 2 || targets[0] != killable.Id || targets[1] != killable.Id {
+		t.Errorf("attacked %v, want both on %d", targets, killable.Id)
+	}
+	if !killable.dead {
+		t.Errorf("the zombie they went for survived")
+	}
+}
+
+func TestSquadShares(t *testing.T) {
+	g := plannerGraph()
+	people := squadPeople(g, g.Node(0), 2)
+	people[0].Thirst = 150
+	people[1].Items = []Item{WaterBottle}
+
+	var shares []Event
+	g.Subscribe(func(e Event) {
+		if e.Kind == Share {
+			shares = append(shares, e)
+		}
+	})
+	g.Step()
+	if len(shares) != 1 || shares[0].Actor.Id != people[1].Id || shares[0].Target.Id != people[0].Id || shares[0].Item != WaterBottle {
+		t.Fatalf("got shares %+v, want the bottle handed over", shares)
+	}
+	if people[0].thirsty() || people[1].Holding(WaterBottle) {
+		t.Errorf("the bottle wasn't drunk")
+	}
+
+	// Nobody gives away their last bottle when they need it themselves
+	people[0].Thirst, people[1].Thirst = 150, 150
+	people[1].Items = []Item{WaterBottle}
+	g.Step()
+	if len(shares) != 1 {
+		t.Errorf("shared a bottle the giver needed")
+	}
+}
+
+func TestSquadSplits(t *testing.T) {
+	g := plannerGraph()
+	people := squadPeople(g, g.Node(0), 3)
+	people[1].Items = []Item{Pistol}
+	g.formSquads()
+	s := people[0].Squad()
+
+	g.InfectPerson(people[1])
+	if people[1].Squad() != nil || s.Leader != people[0] || len(s.Members) != 2 {
+		t.Errorf("got squad %+v after the leader was infected, want the rest led by %d", s, people[0].Id)
+	}
+	people[2].kill(g, Starvation, nil)
+	if people[0].Squad() != nil {
+		t.Errorf("a squad of one is still a squad")
+	}
+}
+
+func TestSquadSurvivesSnapshot(t *testing.T) {
+	g := plannerGraph()
+	people := squadPeople(g, g.Node(0), 3)
+	people[2].Items = []Item{Pistol}
+	g.formSquads()
+
+	f := g.MapFile()
+	if problems := f.Validate(); len(problems) > 0 {
+		t.Errorf("saved squads don't validate: %v", problems)
+	}
+	data, err := g.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range people {
+		r := findPerson(g.Node(0), p.Id)
+		if r == nil || r.Squad() == nil || r.Squad().Leader.Id != people[2].Id || len(r.Squad().Members) != 3 {
+			t.Errorf("%d isn't in %d's squad after restoring", p.Id, people[2].Id)
+		}
+	}
+
+	// Following someone who isn't leading a squad
+	leader := people[0].Id
+	f.Nodes[0].People[1].Leader = &leader
+	if problems := f.Validate(); len(problems) == 0 {
+		t.Errorf("a squad leader following someone else validates")
+	}
+}
+
+func TestSquadsGoWithPopulation(t *testing.T) {
+	g := plannerGraph()
+	people := squadPeople(g, g.Node(0), 3)
+	g.formSquads()
+	s := people[0].Squad()
+
+	rules := PopulationRules{[]PopulationRule{{At: "*", Scatter: 2}}}
+	if err := rules.Populate(g); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Members) != 0 || s.Leader != nil {
+		t.Errorf("got squad %+v after repopulating, want it broken up", s)
+	}
+	for _, p := range people {
+		if p.Squad() != nil {
+			t.Errorf("%d is still in a squad after being removed", p.Id)
+		}
+	}
+}
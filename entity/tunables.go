@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The numbers which decide how the simulation plays out
+type Tunables struct {
+	Starvation       uint // Hunger at which a person starves to death
+	Dehydration      uint // Thirst at which a person dies of thirst
+	ZombieStarvation int  // Hunger at which a zombie starves
+
+	// A person with nothing better to do wanders to a neighbouring vertex one tick in MoveChance, on average
+	MoveChance int
+
+	InventoryCap int // Most items a person can carry
+
+	// Seconds each point of fortification holds a zombie up when breaking into an occupied vertex
+	FortificationSeconds float64
+
+	SquadSize int // Most people in a squad. Below 2, nobody forms one.
+}
+
+var DefaultTunables = Tunables{
+	Starvation:           400,
+	Dehydration:          300,
+	ZombieStarvation:     150,
+	MoveChance:           100,
+	InventoryCap:         3,
+	FortificationSeconds: 2,
+	SquadSize:            4,
+}
+
+var TunableNames = []string{"Starvation", "Dehydration", "ZombieStarvation", "MoveChance", "InventoryCap", "FortificationSeconds", "SquadSize"}
+
+// Set a tunable by name (case-insensitive)
+func (t *Tunables) Set(name string, value float64) error {
+	if value < 0 {
+		return fmt.Errorf("%s can't be negative", name)
+	}
+
+	switch strings.ToLower(name) {
+	case "starvation":
+		t.Starvation = uint(value)
+	case "dehydration":
+		t.Dehydration = uint(value)
+	case "zombiestarvation":
+		t.ZombieStarvation = int(value)
+	case "movechance":
+		if value < 1 {
+			return fmt.Errorf("MoveChance must be at least 1")
+		}
+		t.MoveChance = int(value)
+	case "inventorycap":
+		t.InventoryCap = int(value)
+	case "fortificationseconds":
+		t.FortificationSeconds = value
+	case "squadsize":
+		t.SquadSize = int(value)
+	default:
+		return fmt.Errorf("no tunable named %q (try one of %s)", name, strings.Join(TunableNames, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package entity
+
+import (
+	"fmt"
+)
+
+// Something wrong with a map file, found by Validate
+type Problem struct {
+	Vertex  int    // ID of the vertex concerned, or -1 if it isn't about a vertex
+	Name    string // Name of the vertex concerned, if it has one
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Vertex < 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%s (ID %d): %s", p.Name, p.Vertex, p.Message)
+}
+
+// Check a map for everything which would cause trouble once loaded, returning all the problems found
+func (f *MapFile) Validate() []Problem {
+	var problems []Problem
+	report := func(n *NodeFile, format string, args ...interface{}) {
+		p := Problem{-1, "", fmt.Sprintf(format, args...)}
+		if n != nil {
+			p.Vertex, p.Name = n.Id, n.Name
+		}
+		problems = append(problems, p)
+	}
+
+	nodes := make(map[int]*NodeFile)
+	names := make(map[string]*NodeFile)
+	entities := make(map[uint]*NodeFile)
+	for i := range f.Nodes {
+		n := &f.Nodes[i]
+
+		if other, ok := nodes[n.Id]; ok {
+			report(n, "has the same ID as %s", other.Name)
+		} else {
+			nodes[n.Id] = n
+		}
+		if n.Name == "" {
+			report(n, "has no name")
+		} else if other, ok := names[n.Name]; ok {
+			report(n, "has the same name as vertex %d", other.Id)
+		} else {
+			names[n.Name] = n
+		}
+		if n.Fortification < 0 {
+			report(n, "has negative fortification %d", n.Fortification)
+		}
+		if !f.Bounds.contains(n.Pos) {
+			report(n, "is outside the map bounds, at (%g, %g)", n.Pos.X, n.Pos.Y)
+		}
+
+		for _, item := range n.Items {
+			if !item.Valid() {
+				report(n, "holds invalid item %d", item)
+			}
+		}
+
+		for _, p := range n.People {
+			if other, ok := entities[p.Id]; ok {
+				report(n, "person %d has the same ID as an entity at %s", p.Id, other.Name)
+			}
+			entities[p.Id] = n
+			if !p.Profession.Valid() {
+				report(n, "person %d has invalid profession %d", p.Id, p.Profession)
+			}
+			if p.Location != nil && *p.Location != n.Id {
+				report(n, "person %d has location %d, which isn't the vertex holding them", p.Id, *p.Location)
+			}
+			for _, item := range p.Items {
+				if !item.Valid() {
+					report(n, "person %d holds invalid item %d", p.Id, item)
+				}
+			}
+		}
+		leaders := make(map[uint]*uint)
+		for _, p := range n.People {
+			leaders[p.Id] = p.Leader
+		}
+		for _, p := range n.People {
+			if p.Leader == nil {
+				continue
+			}
+			if l, ok := leaders[*p.Leader]; !ok {
+				report(n, "person %d has squad leader %d, who isn't at the same vertex", p.Id, *p.Leader)
+			} else if l == nil || *l != *p.Leader {
+				report(n, "person %d has squad leader %d, who doesn't lead their own squad", p.Id, *p.Leader)
+			}
+		}
+
+		for _, z := range n.Zombies {
+			if other, ok := entities[z.Id]; ok {
+				report(n, "zombie %d has the same ID as an entity at %s", z.Id, other.Name)
+			}
+			entities[z.Id] = n
+			if z.Holding != nil && *z.Holding != Nothing && !z.Holding.Valid() {
+				report(n, "zombie %d holds invalid item %d", z.Id, *z.Holding)
+			}
+		}
+	}
+
+	degree := make(map[int]int)
+	type pair struct{ from, to int }
+	edges := make(map[pair]bool)
+	for _, e := range f.Edges {
+		from, fromOK := nodes[e.From]
+		to, toOK := nodes[e.To]
+		switch {
+		case !fromOK && !toOK:
+			report(nil, "edge from %d to %d: neither vertex exists", e.From, e.To)
+			continue
+		case !toOK:
+			report(from, "has an edge to vertex %d, which doesn't exist", e.To)
+			continue
+		case !fromOK:
+			report(to, "has an edge from vertex %d, which doesn't exist", e.From)
+			continue
+		case e.From == e.To:
+			report(from, "has an edge to itself")
+			continue
+		}
+
+		p := pair{e.From, e.To}
+		if p.from > p.to {
+			p.from, p.to = p.to, p.from
+		}
+		if edges[p] {
+			report(from, "has more than one edge to %s (ID %d)", to.Name, to.Id)
+		}
+		edges[p] = true
+
+		if e.Weight < 0 {
+			report(from, "has an edge to %s (ID %d) with negative weight %g", to.Name, to.Id, e.Weight)
+		}
+		degree[e.From]++
+		degree[e.To]++
+	}
+
+	for i := range f.Nodes {
+		n := &f.Nodes[i]
+		if degree[n.Id] == 0 && len(f.Nodes) > 1 {
+			report(n, "isn't connected to any other vertex")
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,78 @@
+// Exporting maps to DOT and GraphML, from the command line or from the visualizer.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/3541/zombies/entity"
+)
+
+func exportMain(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "dot", "output format, dot or graphml")
+	live := flags.Bool("live", false, "include the people, zombies and items on each vertex")
+	output := flags.String("o", "", "file to write to, instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zombies export [flags] [map.json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	path := "map.json"
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+	g, err := loadGraph(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *format {
+	case "dot":
+		err = g.WriteDOT(out, *live)
+	case "graphml":
+		err = g.WriteGraphML(out, *live)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Dump the live state of the simulation to state-<tick>.dot and state-<tick>.graphml
+func exportState(g *entity.MapGraph) (string, error) {
+	base := fmt.Sprintf("state-%d", g.Clock.Ticks)
+	for _, e := range []struct {
+		ext   string
+		write func(io.Writer, bool) error
+	}{{"dot", g.WriteDOT}, {"graphml", g.WriteGraphML}} {
+		f, err := os.Create(base + "." + e.ext)
+		if err != nil {
+			return "", err
+		}
+		err = e.write(f, true)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return base, nil
+}
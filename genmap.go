@@ -0,0 +1,51 @@
+// Generating town maps, for trying the simulation out on many different topologies.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/3541/zombies/entity"
+)
+
+func genmapMain(args []string) {
+	flags := flag.NewFlagSet("genmap", flag.ExitOnError)
+	n := flags.Int("n", 64, "number of vertices")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed for generating the map")
+	width := flags.Float64("width", 0, "width of the map bounds (sized to fit the vertices if not given)")
+	height := flags.Float64("height", 0, "height of the map bounds (sized to fit the vertices if not given)")
+	water := flags.Int("water", 0, "water sources to have at least (default one for every 16 vertices)")
+	output := flags.String("o", "generated.json", "file to write the map to")
+	flags.Parse(args)
+
+	if *n < 1 || (*width > 0) != (*height > 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	o := entity.GenOptions{Vertices: *n, Bounds: entity.R(0, 0, *width, *height), Water: *water}
+	if o.Water == 0 {
+		o.Water = (*n + 15) / 16
+	}
+	g, err := entity.GenerateMap(o, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	s, err := g.Serialize()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = ioutil.WriteFile(*output, s, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Generated %d vertices and %d edges (seed %d), wrote %s\n", len(g.Nodes()), len(g.Edges()), *seed, *output)
+}
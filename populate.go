@@ -0,0 +1,100 @@
+// Generating a population from rules, saved into a map or as a scenario for it.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/3541/zombies/entity"
+)
+
+const POPULATION_PATH = "population.json"
+
+func populateMain(args []string) {
+	flags := flag.NewFlagSet("populate", flag.ExitOnError)
+	rulesPath := flags.String("rules", POPULATION_PATH, "population rules to follow")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed for placing people")
+	output := flags.String("o", "", "file to write to, instead of overwriting the map")
+	scenario := flags.Bool("scenario", false, "write a scenario which sets the population up on the map, instead of a map")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zombies populate [flags] [map.json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	mapPath := "map.json"
+	if flags.NArg() > 0 {
+		mapPath = flags.Arg(0)
+	}
+	if *scenario && *output == "" {
+		fmt.Fprintln(os.Stderr, "-scenario needs -o")
+		os.Exit(2)
+	}
+
+	rules, err := entity.LoadPopulationRules(*rulesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	g, err := loadGraph(mapPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	g.Seed(*seed)
+	err = rules.Populate(g)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *rulesPath, err)
+		os.Exit(1)
+	}
+
+	var data []byte
+	if *scenario {
+		data, err = populationScenario(g, mapPath, *output)
+	} else {
+		data, err = g.Serialize()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	path := mapPath
+	if *output != "" {
+		path = *output
+	}
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	people, _ := g.Population()
+	fmt.Printf("Placed %d people (seed %d), wrote %s\n", people, *seed, path)
+}
+
+// A scenario file setting g's population up, with its map path relative to where the scenario is written
+func populationScenario(g *entity.MapGraph, mapPath, scenarioPath string) ([]byte, error) {
+	rel := mapPath
+	from, err := filepath.Abs(filepath.Dir(scenarioPath))
+	if err != nil {
+		return nil, err
+	}
+	to, err := filepath.Abs(mapPath)
+	if err != nil {
+		return nil, err
+	}
+	if r, err := filepath.Rel(from, to); err == nil {
+		rel = r
+	}
+
+	name := strings.TrimSuffix(filepath.Base(scenarioPath), filepath.Ext(scenarioPath))
+	return json.MarshalIndent(g.Scenario(name, rel), "", "    ")
+}
@@ -0,0 +1,67 @@
+// Playback of a recorded simulation in the visualizer, in place of the live one (see entry in zombies.go)
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/3541/zombies/entity"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// How far the '-' and '=' keys seek, in ticks
+const SEEK_TICKS = 60
+
+// Set when running `zombies replay`
+var replayPath string
+
+func replayMain(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zombies replay run.jsonl")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	replayPath = flags.Arg(0)
+
+	pixelgl.Run(entry)
+}
+
+func loadReplay(path string, g *entity.MapGraph) (*entity.Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return entity.NewReplay(f, g)
+}
+
+// Handle seeking. Returns true if the position changed.
+func replayControls(window *pixelgl.Window, r *entity.Replay, g *entity.MapGraph) bool {
+	now := g.Clock.Ticks
+	switch {
+	case window.JustPressed(pixelgl.KeyHome):
+		r.Seek(0)
+	case window.JustPressed(pixelgl.KeyEnd):
+		r.Seek(r.Length())
+	case window.JustPressed(pixelgl.KeyMinus):
+		if now > SEEK_TICKS {
+			r.Seek(now - SEEK_TICKS)
+		} else {
+			r.Seek(0)
+		}
+	case window.JustPressed(pixelgl.KeyEqual):
+		r.Seek(now + SEEK_TICKS)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+// Headless simulation. Runs an outbreak to completion without opening a window.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/3541/zombies/entity"
+)
+
+// Load a map file into a fresh graph, with no window attached
+func loadGraph(path string) (*entity.MapGraph, error) {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g := entity.NewMapGraph(entity.R(0, 0, 1000, 1000), 25)
+	err = g.Deserialize(s)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Load a map into g, and set a scenario up on it if there is one. The scenario's map, if it names one,
+// takes the place of mapPath. The RNG is seeded before the scenario places anything.
+func setupWorld(g *entity.MapGraph, mapPath string, scenarioPath string, seed int64) error {
+	var scenario *entity.Scenario
+	if scenarioPath != "" {
+		var err error
+		scenario, err = entity.LoadScenario(scenarioPath)
+		if err != nil {
+			return err
+		}
+		if scenario.Map != "" {
+			mapPath = scenario.Map
+		}
+	}
+
+	s, err := ioutil.ReadFile(mapPath)
+	if err != nil {
+		return err
+	}
+	err = g.Deserialize(s)
+	if err != nil {
+		return err
+	}
+	g.Seed(seed)
+
+	if scenario != nil {
+		err = scenario.Apply(g)
+		if err != nil {
+			return fmt.Errorf("%s: %s", scenarioPath, err)
+		}
+	}
+	return nil
+}
+
+func simMain(args []string) {
+	flags := flag.NewFlagSet("sim", flag.ExitOnError)
+	mapPath := flags.String("map", "map.json", "map file to load")
+	start := flags.String("start", "", "name of the vertex to place the first zombie on")
+	verbose := flags.Bool("v", false, "print every log message as it happens")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed for the simulation RNG")
+	record := flags.String("record", "", "record the simulation to this file, for replaying later")
+	resume := flags.String("snapshot", "", "resume from a snapshot, instead of loading the map")
+	metricsPath := flags.String("metrics", "", "write per-tick population counts to this CSV file")
+	scenarioPath := flags.String("scenario", "", "scenario to set up, on its own map if it names one")
+	flags.Parse(args)
+
+	g := entity.NewMapGraph(entity.R(0, 0, 1000, 1000), 25)
+	var err error
+	if *resume != "" {
+		err = restoreSnapshot(*resume, g)
+	} else {
+		err = setupWorld(g, *mapPath, *scenarioPath, *seed)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var metrics *entity.Metrics
+	if *metricsPath != "" {
+		metrics = entity.NewMetrics(g)
+	}
+
+	if *start != "" {
+		v := g.GetVertexByName(*start)
+		if v == nil {
+			fmt.Fprintf(os.Stderr, "No vertex named %q\n", *start)
+			os.Exit(1)
+		}
+		g.AddNewZombie(v)
+	}
+
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		recorder, err := entity.NewRecorder(f, g)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	if *verbose {
+		g.Subscribe(func(e entity.Event) {
+			fmt.Printf("[%s] %s\n", time.Duration(e.Time)*entity.TickDuration, g.Describe(e))
+		})
+	}
+	if *resume == "" {
+		g.StartEntities()
+	}
+
+	o := g.RunOutbreak()
+	// A resumed run carries on with the RNG saved in the snapshot, not the seed flag
+	from := fmt.Sprintf("seed %d", *seed)
+	if *resume != "" {
+		from = "from " + *resume
+	}
+	fmt.Printf("Outbreak (%s) ended after %s with %d survivors and %d zombies.\n", from, g.Clock.Time(), o.Survivors, o.Zombies)
+	if metrics != nil {
+		if err := saveMetrics(*metricsPath, metrics); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	g.Mutex.RLock()
+	for _, v := range g.Nodes() {
+		for _, p := range v.People {
+			fmt.Printf("%d (%s) survived at %s\n", p.Id, p.Profession, strings.ToUpper(v.Name))
+		}
+	}
+	g.Mutex.RUnlock()
+}
+
+// Stop collecting metrics, and write them out
+func saveMetrics(path string, m *entity.Metrics) error {
+	m.Stop()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.WriteCSV(f)
+}
@@ -0,0 +1,217 @@
+// Parameter sweeps. Runs a batch for every combination of values of some tunables, and writes the outcomes to CSV.
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3541/zombies/entity"
+)
+
+// A tunable and the values to try it with
+type sweepRange struct {
+	name   string
+	values []float64
+}
+
+// Parse Name=start:end:step, or Name=a,b,c
+func parseSweepRange(s string) (sweepRange, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return sweepRange{}, fmt.Errorf("%q should look like Name=start:end:step or Name=a,b,c", s)
+	}
+	r := sweepRange{name: parts[0]}
+	// Check the name before running anything
+	var t entity.Tunables
+	if err := t.Set(r.name, 1); err != nil {
+		return r, err
+	}
+
+	if strings.Contains(parts[1], ":") {
+		bounds := strings.Split(parts[1], ":")
+		if len(bounds) != 3 {
+			return r, fmt.Errorf("%q should look like start:end:step", parts[1])
+		}
+		var numbers [3]float64
+		for i, b := range bounds {
+			n, err := strconv.ParseFloat(b, 64)
+			if err != nil {
+				return r, err
+			}
+			numbers[i] = n
+		}
+		start, end, step := numbers[0], numbers[1], numbers[2]
+		if step <= 0 || end < start {
+			return r, fmt.Errorf("%q doesn't describe any values", parts[1])
+		}
+		// Count steps rather than accumulating, so rounding doesn't lose the last value
+		for i := 0; start+float64(i)*step <= end+step/1e6; i++ {
+			r.values = append(r.values, start+float64(i)*step)
+		}
+	} else {
+		for _, v := range strings.Split(parts[1], ",") {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return r, err
+			}
+			r.values = append(r.values, n)
+		}
+	}
+
+	// And every value, so no run quietly uses the default in place of one which can't be set
+	for _, v := range r.values {
+		if err := t.Set(r.name, v); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// Repeatable -vary flags
+type sweepRanges []sweepRange
+
+func (r *sweepRanges) String() string {
+	names := make([]string, len(*r))
+	for i, s := range *r {
+		names[i] = s.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (r *sweepRanges) Set(s string) error {
+	sr, err := parseSweepRange(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, sr)
+	return nil
+}
+
+func sweepMain(args []string) {
+	var ranges sweepRanges
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	flags.Var(&ranges, "vary", "tunable to vary, as Name=start:end:step or Name=a,b,c (may be repeated) - one of "+strings.Join(entity.TunableNames, ", "))
+	n := flags.Int("n", 100, "number of outbreaks to run for each combination of values")
+	mapPath := flags.String("map", "map.json", "map file to load")
+	start := flags.String("start", "", "name of the vertex to place the first zombie on")
+	jobs := flags.Int("j", runtime.NumCPU(), "number of outbreaks to run at once")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed for the first outbreak of each batch; each after it uses the next")
+	output := flags.String("o", "sweep.csv", "CSV file to write")
+	scenarioPath := flags.String("scenario", "", "scenario to set up, on its own map if it names one")
+	flags.Parse(args)
+
+	if (*start == "" && *scenarioPath == "") || len(ranges) == 0 || *n < 1 || *jobs < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	out := csv.NewWriter(f)
+
+	header := []string{}
+	for _, r := range ranges {
+		header = append(header, r.name)
+	}
+	header = append(header, "runs", "survival_rate", "mean_survivors", "median_survivors", "mean_peak_zombies", "mean_minutes", "mean_infected", "mean_killed", "mean_starved", "mean_thirst")
+	out.Write(header)
+
+	total := 1
+	for _, r := range ranges {
+		total *= len(r.values)
+	}
+	fmt.Printf("Sweeping %d combinations of %s, %d outbreaks each (seed %d)\n", total, &ranges, *n, *seed)
+
+	// Every combination of values, counting through them like an odometer
+	point := make([]int, len(ranges))
+	for done := 0; done < total; done++ {
+		t := entity.DefaultTunables
+		row := []string{}
+		for i, r := range ranges {
+			if err := t.Set(r.name, r.values[point[i]]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			row = append(row, strconv.FormatFloat(r.values[point[i]], 'g', -1, 64))
+		}
+
+		// Every point uses the same seeds, so differences are down to the tunables
+		outcomes, err := runBatch(*mapPath, *scenarioPath, *start, t, *n, *jobs, *seed)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		row = append(row, summariseOutcomes(outcomes)...)
+		out.Write(row)
+		out.Flush()
+		fmt.Printf("[%d/%d] %s\n", done+1, total, strings.Join(row, ","))
+
+		for i := len(point) - 1; i >= 0; i-- {
+			point[i]++
+			if point[i] < len(ranges[i].values) {
+				break
+			}
+			point[i] = 0
+		}
+	}
+
+	if err := out.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", *output)
+}
+
+// The statistics columns of a sweep row
+func summariseOutcomes(outcomes []entity.Outcome) []string {
+	var survived, peak, infected, killed, starved, thirst float64
+	var minutes time.Duration
+	survivors := make([]float64, len(outcomes))
+	for i, o := range outcomes {
+		if o.Survivors > 0 {
+			survived++
+		}
+		survivors[i] = float64(o.Survivors)
+		peak += float64(o.PeakZombies)
+		minutes += time.Duration(o.Ticks) * entity.TickDuration
+		infected += float64(o.Infected)
+		killed += float64(o.Deaths[entity.Killed])
+		starved += float64(o.Deaths[entity.Starvation])
+		thirst += float64(o.Deaths[entity.Thirst])
+	}
+	sort.Float64s(survivors)
+	sum := 0.0
+	for _, s := range survivors {
+		sum += s
+	}
+
+	n := float64(len(outcomes))
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+	return []string{
+		strconv.Itoa(len(outcomes)),
+		format(survived / n),
+		format(sum / n),
+		format(percentile(survivors, 50)),
+		format(peak / n),
+		format(minutes.Minutes() / n),
+		format(infected / n),
+		format(killed / n),
+		format(starved / n),
+		format(thirst / n),
+	}
+}
@@ -0,0 +1,68 @@
+// Map checking. Reports everything wrong with a map file without running it.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/3541/zombies/entity"
+)
+
+func validateMain(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zombies validate [map.json ...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"map.json"}
+	}
+
+	ok := true
+	for _, path := range paths {
+		if !validateMap(path) {
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// Print every problem with a map file, returning whether there were none
+func validateMap(path string) bool {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	f, err := entity.ParseMapFile(s)
+	if err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		return false
+	}
+	problems := f.Validate()
+
+	// Loading stops at the first structural problem, so only mention it if Validate missed it
+	g := entity.NewMapGraph(entity.R(0, 0, 1000, 1000), 25)
+	err = g.Deserialize(s)
+	if err != nil && len(problems) == 0 {
+		fmt.Printf("%s: failed to load: %s\n", path, err)
+		return false
+	}
+
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", path, p)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK (%d vertices, %d edges)\n", path, len(g.Nodes()), len(g.Edges()))
+	}
+	return len(problems) == 0
+}
@@ -0,0 +1,131 @@
+// Population strip chart, drawn in screen space
+
+package vis
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/3541/zombies/entity"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+// How much simulation time the chart covers
+const CHART_SPAN = 3 * time.Minute
+
+type chartSample struct {
+	tick    uint64
+	people  int
+	zombies int
+	items   int // Items which can still be picked up
+}
+
+type chart struct {
+	draw   *imdraw.IMDraw
+	legend *text.Text
+	bounds pixel.Rect
+
+	samples []chartSample
+}
+
+func newChart(atlas *text.Atlas, bounds pixel.Rect) *chart {
+	return &chart{imdraw.New(nil), text.New(pixel.V(bounds.Max.X+10, bounds.Max.Y-atlas.LineHeight()), atlas), bounds, nil}
+}
+
+func (c *chart) span() uint64 {
+	return uint64(CHART_SPAN / entity.TickDuration)
+}
+
+// Take a sample of the map as it is now
+func (c *chart) sample(g *entity.MapGraph) {
+	s := chartSample{tick: g.Clock.Ticks}
+	for _, n := range g.Nodes() {
+		s.people += len(n.People)
+		s.zombies += len(n.Zombies)
+		for _, i := range n.Items {
+			if !i.Fixed() {
+				s.items++
+			}
+		}
+	}
+
+	// Time goes backwards when a replay seeks back, or a snapshot is restored
+	for len(c.samples) > 0 && c.samples[len(c.samples)-1].tick >= s.tick {
+		c.samples = c.samples[:len(c.samples)-1]
+	}
+	// Keep the last sample from before the window, so the lines reach its left edge
+	for len(c.samples) > 1 && c.samples[1].tick+c.span() < s.tick {
+		c.samples = c.samples[1:]
+	}
+	c.samples = append(c.samples, s)
+}
+
+func (c *chart) render(now uint64) {
+	c.draw.Reset()
+	c.draw.Clear()
+
+	c.draw.Color = colornames.Lightgray
+	c.draw.Push(c.bounds.Min, c.bounds.Max)
+	c.draw.Rectangle(1)
+
+	if len(c.samples) == 0 {
+		return
+	}
+
+	max := 1
+	for _, s := range c.samples {
+		for _, v := range []int{s.people, s.zombies, s.items} {
+			if v > max {
+				max = v
+			}
+		}
+	}
+
+	var start uint64
+	if now > c.span() {
+		start = now - c.span()
+	}
+	point := func(tick uint64, v int) pixel.Vec {
+		x := 0.0
+		if tick > start {
+			x = float64(tick-start) / float64(c.span())
+		}
+		return pixel.V(c.bounds.Min.X+x*c.bounds.W(), c.bounds.Min.Y+float64(v)/float64(max)*c.bounds.H())
+	}
+
+	// Counts only change at samples, so each line is drawn as steps
+	line := func(col color.Color, value func(chartSample) int) {
+		c.draw.Color = col
+		for i, s := range c.samples {
+			next := now
+			if i+1 < len(c.samples) {
+				next = c.samples[i+1].tick
+			}
+			c.draw.Push(point(s.tick, value(s)), point(next, value(s)))
+		}
+		c.draw.Line(2)
+	}
+	line(colornames.Green, func(s chartSample) int { return s.people })
+	line(colornames.Red, func(s chartSample) int { return s.zombies })
+	line(colornames.Blue, func(s chartSample) int { return s.items })
+
+	last := c.samples[len(c.samples)-1]
+	c.legend.Clear()
+	c.legend.Color = colornames.Green
+	fmt.Fprintf(c.legend, "PEOPLE %d\n", last.people)
+	c.legend.Color = colornames.Red
+	fmt.Fprintf(c.legend, "ZOMBIES %d\n", last.zombies)
+	c.legend.Color = colornames.Blue
+	fmt.Fprintf(c.legend, "ITEMS %d\n", last.items)
+}
+
+// Draw the population chart. Should be called with an untransformed window.
+func (w *VWindow) DrawChart() {
+	w.chart.render(w.Graph.Clock.Ticks)
+	w.chart.draw.Draw(w.window)
+	w.chart.legend.Draw(w.window, pixel.IM)
+}
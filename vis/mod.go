@@ -3,7 +3,9 @@
 package vis
 
 import (
+	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/3541/zombies/entity"
@@ -19,8 +21,18 @@ type VWindow struct {
 	window     *pixelgl.Window
 	draw       *imdraw.IMDraw
 	atlas      *text.Atlas
+	labelAtlas *text.Atlas
 	StatusText *text.Text
 
+	// Vertex labels, pre-rendered and keyed by vertex ID
+	labels map[int]*text.Text
+
+	// Vertex highlighted by the map editor
+	Selected *entity.PositionedNode
+
+	// Population over time, drawn next to the FPS counter
+	chart *chart
+
 	Graph *entity.MapGraph
 }
 
@@ -34,8 +46,69 @@ func NewVWindow(window *pixelgl.Window, draw *imdraw.IMDraw, statusAtlas *text.A
 	t.WriteString("Left-click on a vertex to add a zombie.\n")
 	t.WriteString("Right-click on a vertex to infect all people on that vertex.\n")
 	t.WriteString("Use the arrow keys to move the camera, the '.' key to zoom, and the ',' key to zoom out.\n")
+	t.WriteString("Press space to pause, ']' to speed up, '[' to slow down, and 'n' to advance one tick while paused.\n")
+	t.WriteString("Press F5 to save a snapshot of the simulation, and F9 to go back to the last one.\n")
+	t.WriteString("Press F6 to export the map as it stands to DOT and GraphML.\n")
 
-	return &VWindow{window, draw, statusAtlas, t /*(25.0 / bounds.W()) * window.Bounds().H()*/, entity.NewMapGraph(labelAtlas, bounds, 25)}
+	w := &VWindow{window, draw, statusAtlas, labelAtlas, t /*(25.0 / bounds.W()) * window.Bounds().H()*/, make(map[int]*text.Text), nil, newChart(statusAtlas, pixel.R(400, 10, 700, 110)), entity.NewMapGraph(entity.R(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y), 25)}
+	w.Graph.Subscribe(w.logEvent)
+	return w
+}
+
+// Print the interesting simulation events to the status text
+func (w *VWindow) logEvent(e entity.Event) {
+	switch e.Kind {
+	case entity.Attack, entity.Infection, entity.Death, entity.BreakIn, entity.Flee, entity.Share:
+		fmt.Fprintln(w, w.Graph.Describe(e))
+	case entity.Move:
+		// Only worth mentioning if a zombie has got in somewhere
+		to := w.Graph.Node(e.To)
+		if e.Actor.Zombie && len(to.People) > 0 {
+			fmt.Fprintf(w, "ZOMBIE successfully broke into %s from %s\n", to.Name, w.Graph.Node(e.Vertex).Name)
+		}
+	}
+}
+
+func vec(v entity.Vec) pixel.Vec {
+	return pixel.V(v.X, v.Y)
+}
+
+// Pre-render a vertex label
+func (w *VWindow) renderLabel(n *entity.PositionedNode) *text.Text {
+	t := text.New(vec(n.Pos), w.labelAtlas)
+	t.Color = colornames.Black
+	// Write to a buffer so that the size can be checked for centering purposes
+	var b bytes.Buffer
+	b.WriteString(n.Name)
+	if len(n.Items) > 0 {
+		// Prevent double-prinitng of item duplicates.
+		seen := make(map[entity.Item]bool)
+		seen[n.Items[0]] = true
+		b.WriteString(fmt.Sprintf(" (%s", n.Items[0]))
+		for _, i := range n.Items[1:] {
+			if !seen[i] {
+				b.WriteString(fmt.Sprintf(", %s", i))
+				seen[i] = true
+			}
+		}
+		b.WriteString(")")
+	}
+	s := b.String()
+	// Center text
+	t.Dot.X -= t.BoundsOf(s).W() / 2
+	fmt.Fprintln(t, s)
+	t.Dot.X -= t.BoundsOf(strconv.Itoa(n.Weight)).W() / 2
+	fmt.Fprintln(t, n.Weight)
+	return t
+}
+
+// Re-render all vertex labels, dropping those of deleted vertices
+func (w *VWindow) renderLabels(nodes []*entity.PositionedNode) {
+	labels := make(map[int]*text.Text, len(nodes))
+	for _, n := range nodes {
+		labels[n.ID()] = w.renderLabel(n)
+	}
+	w.labels = labels
 }
 
 // Implements io.Writer for VWindow, allowing fmt.Println(w, ...) & co., with correct wrapping and scrolling.
@@ -66,6 +139,13 @@ func (w *VWindow) PrintEntityStatus() {
 			} else {
 				fmt.Fprint(w, "NOTHING")
 			}
+			if s := p.Squad(); s != nil {
+				if s.Leader == p {
+					fmt.Fprintf(w, ", leading a squad of %d", len(s.Members))
+				} else {
+					fmt.Fprintf(w, ", in %d's squad", s.Leader.Id)
+				}
+			}
 			fmt.Fprintln(w)
 		}
 	}
@@ -73,6 +153,9 @@ func (w *VWindow) PrintEntityStatus() {
 
 func (w *VWindow) Draw() {
 	if w.Graph.Changed {
+		w.renderLabels(w.Graph.Nodes())
+		w.chart.sample(w.Graph)
+
 		w.draw.Reset()
 		w.draw.Clear()
 		w.draw.Color = colornames.Lightslategray
@@ -80,18 +163,18 @@ func (w *VWindow) Draw() {
 			// Draw vertex
 			if len(n.Zombies) > 0 {
 				w.draw.Color = colornames.Red
-				w.draw.Push(n.Pos)
+				w.draw.Push(vec(n.Pos))
 				w.draw.Circle(w.Graph.VertexSize+2+float64(3*len(n.People))+float64(len(n.Zombies)), float64(2*len(n.Zombies)))
 				w.draw.Color = colornames.Lightslategray
 			}
 			if len(n.People) > 0 {
 				w.draw.Color = colornames.Green
-				w.draw.Push(n.Pos)
+				w.draw.Push(vec(n.Pos))
 				w.draw.Circle(w.Graph.VertexSize+4+float64(len(n.People)), float64(2*len(n.People)))
 				w.draw.Color = colornames.Lightslategray
 			}
-			w.draw.Push(n.Pos)
-			if n.Selected {
+			w.draw.Push(vec(n.Pos))
+			if n == w.Selected {
 				w.draw.Circle(w.Graph.VertexSize, 4)
 			} else {
 				w.draw.Circle(w.Graph.VertexSize, 0)
@@ -100,8 +183,8 @@ func (w *VWindow) Draw() {
 			// Draw edges from that vertex
 			for _, t := range w.Graph.From(n) {
 				t := t.(*entity.PositionedNode)
-				w.draw.Push(n.Pos)
-				w.draw.Push(t.Pos)
+				w.draw.Push(vec(n.Pos))
+				w.draw.Push(vec(t.Pos))
 				weight, _ := w.Graph.Weight(n, t)
 				w.draw.Line(weight * 2)
 			}
@@ -117,7 +200,7 @@ func (w *VWindow) Draw() {
 	w.draw.Draw(w.window)
 
 	// Draw vertex names
-	for _, n := range w.Graph.Nodes() {
-		n.RenderedName.Draw(w.window, pixel.IM)
+	for _, l := range w.labels {
+		l.Draw(w.window, pixel.IM)
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"io/ioutil"
@@ -19,14 +20,24 @@ import (
 
 	"github.com/golang/freetype/truetype"
 
+	"github.com/3541/zombies/entity"
 	"github.com/3541/zombies/vis"
 )
 
 const (
 	CAMERA_SPEED = 600.0
 	ZOOM_SPEED   = 1.01
+
+	ITEMS_PATH       = "items.json"
+	PROFESSIONS_PATH = "professions.json"
 )
 
+var seed = flag.Int64("seed", time.Now().UnixNano(), "seed for the simulation RNG")
+var record = flag.String("record", "", "record the simulation to this file, for replaying later")
+var resume = flag.String("snapshot", "", "resume from a snapshot saved with F5, instead of loading the map")
+var metricsPath = flag.String("metrics", "", "write per-tick population counts to this CSV file on exit")
+var scenarioPath = flag.String("scenario", "", "scenario to set up, on its own map if it names one")
+
 func loadFont(path string, size float64) (font.Face, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -98,12 +109,24 @@ func entry() {
 
 	w := vis.NewVWindow(window, draw, text.NewAtlas(consolasScaled, text.ASCII), text.NewAtlas(consolas, text.ASCII), pixel.R(0, 0, 1000, 1000))
 
-	// Load and parse the map
-	s, _ := ioutil.ReadFile("./map.json")
-
-	err = w.Graph.Deserialize(s)
-	if err != nil {
-		panic(err)
+	// Either play back a recording (see replay.go), or load and parse the map
+	var replay *entity.Replay
+	if replayPath != "" {
+		replay, err = loadReplay(replayPath, w.Graph)
+		if err != nil {
+			panic(err)
+		}
+	} else if *resume != "" {
+		err = restoreSnapshot(*resume, w.Graph)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		err = setupWorld(w.Graph, "./map.json", *scenarioPath, *seed)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Using seed %d\n", *seed)
 	}
 
 	/*	w.Graph.AddNode(w.Graph.NewPositionedNode("TEST 1", 500, 500, 2))
@@ -116,8 +139,43 @@ func entry() {
 		w.Graph.AddPerson(entity.Priest, w.Graph.GetVertexByName("TEST 1"))*/
 
 	// Start the map editor when running a debug build (see edit_release.go and edit_debug.go)
-	editInit(window, w.Graph, consolasScaled)
-	w.Graph.StartEntities()
+	if replay == nil {
+		editInit(window, w, consolasScaled)
+		// Entities in a snapshot are already going
+		if *resume == "" {
+			w.Graph.StartEntities()
+		}
+	}
+
+	// The last snapshot saved with F5, to be restored with F9
+	lastSnapshot := *resume
+
+	if *metricsPath != "" && replay == nil {
+		metrics := entity.NewMetrics(w.Graph)
+		defer func() {
+			if err := saveMetrics(*metricsPath, metrics); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		recorder, err := entity.NewRecorder(f, w.Graph)
+		if err != nil {
+			panic(err)
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
 
 	// To track framerate
 	frames := 0
@@ -127,6 +185,14 @@ func entry() {
 	logAtlas := text.NewAtlas(consolas, text.ASCII)
 	logText := text.New(pixel.V(10, 10), logAtlas)
 	logText.Color = colornames.Black
+	fps := 0
+	showStatus := func() {
+		logText.Clear()
+		fmt.Fprintf(logText, "%d  %s", fps, &w.Graph.Clock)
+		if replay != nil {
+			fmt.Fprintf(logText, "  %s / %s", w.Graph.Clock.Time(), time.Duration(replay.Length())*entity.TickDuration)
+		}
+	}
 
 	cameraPosition := window.Bounds().Center()
 	cameraZoom := 1.0
@@ -137,7 +203,8 @@ func entry() {
 	//	g.PopulateMap()
 
 	for !window.Closed() {
-		timeElapsed := time.Since(lastFrame).Seconds()
+		elapsed := time.Since(lastFrame)
+		timeElapsed := elapsed.Seconds()
 		lastFrame = time.Now()
 
 		if window.Pressed(pixelgl.KeyLeft) {
@@ -176,22 +243,69 @@ func entry() {
 			w.StatusText.Clear()
 		}
 
+		// Simulation time controls
+		if window.JustPressed(pixelgl.KeySpace) {
+			w.Graph.Clock.TogglePause()
+			showStatus()
+		}
+
+		if window.JustPressed(pixelgl.KeyRightBracket) {
+			w.Graph.Clock.Faster()
+			showStatus()
+		}
+
+		if window.JustPressed(pixelgl.KeyLeftBracket) {
+			w.Graph.Clock.Slower()
+			showStatus()
+		}
+
+		if window.JustPressed(pixelgl.KeyN) && w.Graph.Clock.Paused {
+			if replay != nil {
+				replay.Step()
+			} else {
+				w.Graph.Step()
+			}
+			showStatus()
+		}
+
+		if replay != nil && replayControls(window, replay, w.Graph) {
+			showStatus()
+		}
+
+		if window.JustPressed(pixelgl.KeyF5) && replay == nil {
+			path := fmt.Sprintf("snapshot-%d.json", w.Graph.Clock.Ticks)
+			err := saveSnapshot(path, w.Graph)
+			if err != nil {
+				fmt.Fprintf(w, "Failed to save snapshot: %s\n", err)
+			} else {
+				fmt.Fprintf(w, "Saved snapshot to %s\n", path)
+				lastSnapshot = path
+			}
+		}
+
+		if window.JustPressed(pixelgl.KeyF9) && replay == nil && lastSnapshot != "" {
+			err := restoreSnapshot(lastSnapshot, w.Graph)
+			if err != nil {
+				fmt.Fprintf(w, "Failed to restore snapshot: %s\n", err)
+			} else {
+				fmt.Fprintf(w, "Restored snapshot from %s\n", lastSnapshot)
+				showStatus()
+			}
+		}
+
+		if window.JustPressed(pixelgl.KeyF6) {
+			base, err := exportState(w.Graph)
+			if err != nil {
+				fmt.Fprintf(w, "Failed to export: %s\n", err)
+			} else {
+				fmt.Fprintf(w, "Exported to %s.dot and %s.graphml\n", base, base)
+			}
+		}
+
 		if window.JustPressed(pixelgl.KeyK) {
-			fmt.Fprintln(w, "CS: CHAINSAW")
-			fmt.Fprintln(w, "PSTL: PISTOL")
-			fmt.Fprintln(w, "RFL: RIFLE")
-			fmt.Fprintln(w, "EB: ENERGY BAR")
-			fmt.Fprintln(w, "WS: WATER SOURCE")
-			fmt.Fprintln(w, "WB: WATER BOTTLE")
-			fmt.Fprintln(w, "RP: RUSTY PIPE")
-			fmt.Fprintln(w, "HTCHT: HATCHET")
-			fmt.Fprintln(w, "IAF: IMPROVISED AEROSOL FLAMETHROWER")
-			fmt.Fprintln(w, "BDG: BANDAGE")
-			fmt.Fprintln(w, "WRNC: WRENCH")
-			fmt.Fprintln(w, "HS: HACKSAW")
-			fmt.Fprintln(w, "RPG: ROCKET-PROPELLED GRENADE LAUNCHER")
-			fmt.Fprintln(w, "ATGM: ANTI-TANK GUIDED MISSILE")
-			fmt.Fprintln(w, "HW: HOLY WATER")
+			for _, i := range entity.Items() {
+				fmt.Fprintf(w, "%s: %s\n", i, i.StringLong())
+			}
 		}
 
 		// Scale viewport to match height of map space
@@ -207,36 +321,96 @@ func entry() {
 		// untransform so fps counter appears in bottom-left of viewport regardless of pan/zoom
 		window.SetMatrix(pixel.IM)
 		logText.Draw(window, pixel.IM)
+		w.DrawChart()
 		w.StatusText.Draw(window, pixel.IM)
 
-		// Do map editor things, if in a debug build
-		editGraph(camera)
+		if replay != nil {
+			replay.Advance(elapsed)
+		} else {
+			// Do map editor things, if in a debug build
+			editGraph(camera)
 
-		loop:
-		for {
-			select {
-			case t := <-w.Graph.Log:
-				fmt.Fprintln(w, t)
-			default:
-				break loop
-			}
+			w.Graph.Advance(elapsed)
 		}
 
 		window.Update()
 
-		// Every second, display the frames rendered in that second
+		// Every second, display the frames rendered in that second, and the simulation speed
 		frames++
 		select {
 		case <-timer:
-			logText.Clear()
-			fmt.Fprintf(logText, "%d", frames)
+			fps = frames
+			showStatus()
 			frames = 0
 		default:
 		}
 	}
-	editEnd(window, w.Graph)
+	if replay == nil {
+		editEnd(window, w.Graph)
+	}
+}
+
+func saveSnapshot(path string, g *entity.MapGraph) error {
+	s, err := g.Snapshot()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, s, 0666)
+}
+
+func restoreSnapshot(path string, g *entity.MapGraph) error {
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return g.Restore(s)
 }
 
 func main() {
+	// Items and professions beyond the built-in ones, if there are any. Professions refer to items.
+	for _, c := range []struct {
+		path string
+		load func(string) error
+	}{{ITEMS_PATH, entity.LoadItems}, {PROFESSIONS_PATH, entity.LoadProfessions}} {
+		if _, err := os.Stat(c.path); err == nil {
+			err = c.load(c.path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Subcommands. Only replay needs a window (see sim.go and replay.go)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "sim":
+			simMain(os.Args[2:])
+			return
+		case "replay":
+			replayMain(os.Args[2:])
+			return
+		case "validate":
+			validateMain(os.Args[2:])
+			return
+		case "export":
+			exportMain(os.Args[2:])
+			return
+		case "batch":
+			batchMain(os.Args[2:])
+			return
+		case "sweep":
+			sweepMain(os.Args[2:])
+			return
+		case "populate":
+			populateMain(os.Args[2:])
+			return
+		case "genmap":
+			genmapMain(os.Args[2:])
+			return
+		}
+	}
+
+	flag.Parse()
 	pixelgl.Run(entry)
 }